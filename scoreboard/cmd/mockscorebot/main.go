@@ -0,0 +1,309 @@
+// Copyright(C) 2020 - 2023 iDigitalFlame
+//
+// This program is free software: you can redistribute it and / or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, either version 3 of the License, or
+// any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.If not, see <https://www.gnu.org/licenses/>.
+//
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"hash/fnv"
+	"math/rand"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/PurpleSec/logx"
+)
+
+const defaults = `{
+    "seed": 0,
+    "tick": 5,
+    "chance": {
+        "flip": 0.05,
+        "offline": 0.01,
+        "beacon": 0.05,
+        "clean": 0.1,
+        "flag": 0.02,
+        "ticket": 0.05,
+        "score": 25
+    },
+    "games": [
+        {
+            "id": 1,
+            "name": "Mock Game",
+            "mode": 0,
+            "credit": "Mock Scorebot",
+            "message": "This is a simulated Game.",
+            "phases": [
+                {"status": "stopped", "duration": 30},
+                {"status": "running", "duration": 600},
+                {"status": "paused", "duration": 60},
+                {"status": "running", "duration": 600},
+                {"status": "completed", "duration": 0}
+            ],
+            "teams": [
+                {
+                    "id": 1,
+                    "name": "Blue Team One",
+                    "logo": "default.png",
+                    "color": "#0000ff",
+                    "hosts": [
+                        {
+                            "id": 1,
+                            "name": "web",
+                            "services": [
+                                {"id": 1, "port": 80, "protocol": "tcp"},
+                                {"id": 2, "port": 443, "protocol": "tcp", "bonus": true}
+                            ]
+                        },
+                        {
+                            "id": 2,
+                            "name": "dns",
+                            "services": [
+                                {"id": 3, "port": 53, "protocol": "udp"},
+                                {"id": 4, "port": 0, "protocol": "icmp"}
+                            ]
+                        }
+                    ]
+                },
+                {
+                    "id": 2,
+                    "name": "Blue Team Two",
+                    "logo": "default.png",
+                    "color": "#00ffff",
+                    "hosts": [
+                        {
+                            "id": 3,
+                            "name": "web",
+                            "services": [
+                                {"id": 5, "port": 80, "protocol": "tcp"},
+                                {"id": 6, "port": 443, "protocol": "tcp", "bonus": true}
+                            ]
+                        },
+                        {
+                            "id": 4,
+                            "name": "mail",
+                            "services": [
+                                {"id": 7, "port": 25, "protocol": "tcp"}
+                            ]
+                        }
+                    ]
+                },
+                {
+                    "id": 3,
+                    "name": "Red Team",
+                    "logo": "default.png",
+                    "color": "#ff0000",
+                    "offense": true,
+                    "hosts": []
+                }
+            ],
+            "events": [
+                {"id": 1, "type": 0, "at": 10, "duration": 0, "data": {"text": "Game started!"}},
+                {"id": 2, "type": 1, "at": 300, "duration": 30, "data": {"title": "Mock Event", "text": "This is a window event."}}
+            ]
+        }
+    ]
+}
+`
+const usage = `Scorebot Mock Server
+
+Serves simulated Scorebot "api/games/" and "api/scoreboard/<id>/" data from a
+scenario file. Service states, hosts, beacons, flags, tickets and scores change
+randomly each tick while a Game is running and Games move through the phases
+listed in the scenario.
+
+Usage of mockscorebot:
+  -c <file>                 Scenario file path (Default uses the "-d" scenario).
+  -d                        Print default scenario and exit.
+  -bind <socket>            Address and port to listen on (Default "0.0.0.0:8000").
+  -seed <number>            Random seed override, zero uses the current time.
+  -tick <seconds>           Simulation tick override, in seconds.
+  -log-level <number [0-5]> Logging level (Default 2).
+`
+
+type server struct {
+	log   logx.Log
+	rand  *rand.Rand
+	games []*mock
+	tick  time.Duration
+	lock  sync.RWMutex
+	c     chance
+}
+
+func main() {
+	var (
+		args      = flag.NewFlagSet("Scorebot Mock Server", flag.ExitOnError)
+		s, b      string
+		d         bool
+		seed      int64
+		tick, lvl int
+		c         scenario
+	)
+	args.Usage = func() {
+		os.Stdout.WriteString(usage)
+		os.Exit(2)
+	}
+	args.StringVar(&s, "c", "", "")
+	args.BoolVar(&d, "d", false, "")
+	args.StringVar(&b, "bind", "0.0.0.0:8000", "")
+	args.Int64Var(&seed, "seed", 0, "")
+	args.IntVar(&tick, "tick", 0, "")
+	args.IntVar(&lvl, "log-level", 2, "")
+	if err := args.Parse(os.Args[1:]); err != nil {
+		os.Stdout.WriteString(usage)
+		os.Exit(2)
+	}
+	if d {
+		os.Stdout.WriteString(defaults)
+		return
+	}
+	v := []byte(defaults)
+	if len(s) > 0 {
+		var err error
+		if v, err = os.ReadFile(s); err != nil {
+			os.Stderr.WriteString(`Error: cannot read file "` + s + `": ` + err.Error() + "!\n")
+			os.Exit(1)
+		}
+	}
+	if err := json.Unmarshal(v, &c); err != nil {
+		os.Stderr.WriteString("Error: cannot parse scenario JSON: " + err.Error() + "!\n")
+		os.Exit(1)
+	}
+	if seed != 0 {
+		c.Seed = seed
+	}
+	if tick > 0 {
+		c.Tick = tick
+	}
+	if err := c.verify(); err != nil {
+		os.Stderr.WriteString("Error: invalid scenario: " + err.Error() + "!\n")
+		os.Exit(1)
+	}
+	if c.Seed == 0 {
+		c.Seed = time.Now().UnixNano()
+	}
+	var (
+		n = time.Now()
+		m = &server{
+			c:     c.Chance,
+			log:   logx.Console(logx.Level(lvl)),
+			rand:  rand.New(rand.NewSource(c.Seed)),
+			tick:  time.Duration(c.Tick) * time.Second,
+			games: make([]*mock, 0, len(c.Games)),
+		}
+		h    = new(http.ServeMux)
+		w    = make(chan os.Signal, 1)
+		x, f = context.WithCancel(context.Background())
+	)
+	for i := range c.Games {
+		m.games = append(m.games, newMock(c.Games[i], n))
+	}
+	h.HandleFunc("/api/games/", m.httpGames)
+	h.HandleFunc("/api/scoreboard/", m.httpScoreboard)
+	l := &http.Server{Addr: b, Handler: h}
+	signal.Notify(w, syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT)
+	m.log.Info(`Mock Scorebot listening on "%s" with %d Games (seed %d)..`, b, len(m.games), c.Seed)
+	go m.run(x)
+	go func() {
+		if err := l.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			m.log.Error("Error during runtime: %s!", err.Error())
+		}
+		f()
+	}()
+	select {
+	case <-w:
+	case <-x.Done():
+	}
+	signal.Stop(w)
+	f()
+	l.Close()
+}
+func (s *server) run(x context.Context) {
+	t := time.NewTicker(s.tick)
+	defer t.Stop()
+	for {
+		select {
+		case <-x.Done():
+			return
+		case n := <-t.C:
+			s.lock.Lock()
+			for _, g := range s.games {
+				if g.advance(n) {
+					s.log.Info(`Game %d is now in phase %d "%s".`, g.meta.ID, g.phase, g.phases[g.phase].Status)
+				}
+				g.step(s.rand, s.c, n)
+			}
+			s.lock.Unlock()
+		}
+	}
+}
+
+// write sends the JSON value to the client, supporting conditional requests with an
+// ETag made from the body hash.
+func (s *server) write(w http.ResponseWriter, r *http.Request, v interface{}) {
+	s.lock.RLock()
+	b, err := json.Marshal(v)
+	s.lock.RUnlock()
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		s.log.Error(`Error during request from "%s": %s!`, r.RemoteAddr, err.Error())
+		return
+	}
+	h := fnv.New64a()
+	h.Write(b)
+	e := `"` + strconv.FormatUint(h.Sum64(), 16) + `"`
+	w.Header().Set("ETag", e)
+	if r.Header.Get("If-None-Match") == e {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(b)
+}
+func (s *server) httpGames(w http.ResponseWriter, r *http.Request) {
+	if strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/games"), "/") != "" {
+		http.NotFound(w, r)
+		return
+	}
+	s.lock.RLock()
+	o := make([]meta, 0, len(s.games))
+	for _, g := range s.games {
+		o = append(o, g.meta)
+	}
+	s.lock.RUnlock()
+	s.write(w, r, o)
+}
+func (s *server) httpScoreboard(w http.ResponseWriter, r *http.Request) {
+	v, err := strconv.ParseUint(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/scoreboard"), "/"), 10, 64)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	for _, g := range s.games {
+		if g.meta.ID == v {
+			s.write(w, r, &g.board)
+			return
+		}
+	}
+	http.NotFound(w, r)
+}
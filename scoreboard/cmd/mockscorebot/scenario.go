@@ -0,0 +1,366 @@
+// Copyright(C) 2020 - 2023 iDigitalFlame
+//
+// This program is free software: you can redistribute it and / or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, either version 3 of the License, or
+// any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.If not, see <https://www.gnu.org/licenses/>.
+//
+
+package main
+
+import (
+	"errors"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	stopped   uint8 = 0x0
+	running   uint8 = 0x1
+	paused    uint8 = 0x2
+	cancelled uint8 = 0x3
+	completed uint8 = 0x4
+)
+
+var states = [...]string{"green", "yellow", "red"}
+
+type phase struct {
+	Status   string `json:"status"`
+	Duration int    `json:"duration"`
+}
+type chance struct {
+	Flip    float64 `json:"flip"`
+	Offline float64 `json:"offline"`
+	Beacon  float64 `json:"beacon"`
+	Clean   float64 `json:"clean"`
+	Flag    float64 `json:"flag"`
+	Ticket  float64 `json:"ticket"`
+	Score   int64   `json:"score"`
+}
+type scenario struct {
+	Games  []gameScenario `json:"games"`
+	Chance chance         `json:"chance"`
+	Seed   int64          `json:"seed"`
+	Tick   int            `json:"tick"`
+}
+type hostScenario struct {
+	Name     string            `json:"name"`
+	Services []serviceScenario `json:"services"`
+	ID       uint64            `json:"id"`
+}
+type teamScenario struct {
+	Name    string         `json:"name"`
+	Logo    string         `json:"logo"`
+	Color   string         `json:"color"`
+	Hosts   []hostScenario `json:"hosts"`
+	ID      uint64         `json:"id"`
+	Minimal bool           `json:"minimal"`
+	Offense bool           `json:"offense"`
+}
+type gameScenario struct {
+	Name    string          `json:"name"`
+	Credit  string          `json:"credit"`
+	Message string          `json:"message"`
+	Phases  []phase         `json:"phases"`
+	Teams   []teamScenario  `json:"teams"`
+	Events  []eventScenario `json:"events"`
+	ID      uint64          `json:"id"`
+	Mode    uint8           `json:"mode"`
+}
+type eventScenario struct {
+	Data     map[string]string `json:"data"`
+	ID       uint64            `json:"id"`
+	At       int               `json:"at"`
+	Duration int               `json:"duration"`
+	Type     uint8             `json:"type"`
+}
+type serviceScenario struct {
+	Protocol string `json:"protocol"`
+	ID       uint64 `json:"id"`
+	Port     uint16 `json:"port"`
+	Bonus    bool   `json:"bonus"`
+}
+
+// The types below match the JSON read by the Scoreboard 'game' package.
+type meta struct {
+	End    time.Time `json:"end"`
+	Start  time.Time `json:"start"`
+	Name   string    `json:"name"`
+	ID     uint64    `json:"id"`
+	Mode   uint8     `json:"mode"`
+	Status uint8     `json:"status"`
+}
+type host struct {
+	Name     string     `json:"name"`
+	Services []*service `json:"services"`
+	ID       uint64     `json:"id"`
+	Online   bool       `json:"online"`
+}
+type team struct {
+	Name    string   `json:"name"`
+	Logo    string   `json:"logo"`
+	Color   string   `json:"color"`
+	Beacons []beacon `json:"beacons"`
+	Hosts   []*host  `json:"hosts"`
+	Flags   flags    `json:"flags"`
+	Score   score    `json:"score"`
+	Tickets tickets  `json:"tickets"`
+	ID      uint64   `json:"id"`
+	Minimal bool     `json:"minimal"`
+	Offense bool     `json:"offense"`
+}
+type flags struct {
+	Open     uint32 `json:"open"`
+	Lost     uint32 `json:"lost"`
+	Captured uint32 `json:"captured"`
+}
+type score struct {
+	Total  int64 `json:"total"`
+	Health int64 `json:"health"`
+}
+type event struct {
+	Data map[string]string `json:"data"`
+	ID   uint64            `json:"id"`
+	Type uint8             `json:"type"`
+}
+type board struct {
+	Name    string  `json:"name"`
+	Credit  string  `json:"credit"`
+	Message string  `json:"message"`
+	Teams   []*team `json:"teams"`
+	Events  []event `json:"events"`
+	Mode    uint8   `json:"mode"`
+}
+type beacon struct {
+	Color string `json:"color"`
+	ID    uint64 `json:"id"`
+	Team  uint64 `json:"team"`
+}
+type service struct {
+	Protocol string `json:"protocol"`
+	State    string `json:"status"`
+	ID       uint64 `json:"id"`
+	Port     uint16 `json:"port"`
+	Bonus    bool   `json:"bool"`
+}
+type tickets struct {
+	Open   uint32 `json:"open"`
+	Closed uint32 `json:"closed"`
+}
+
+// mock is the simulated state of a single Game.
+type mock struct {
+	begin  time.Time
+	board  board
+	events []eventScenario
+	phases []phase
+	meta   meta
+	phase  int
+	beacon uint64
+}
+
+func status(s string) (uint8, error) {
+	switch strings.ToLower(s) {
+	case "stopped", "":
+		return stopped, nil
+	case "running":
+		return running, nil
+	case "paused":
+		return paused, nil
+	case "cancelled":
+		return cancelled, nil
+	case "completed":
+		return completed, nil
+	}
+	return 0, errors.New(`invalid status "` + s + `"`)
+}
+func (s *scenario) verify() error {
+	if s.Tick <= 0 {
+		return errors.New("tick " + strconv.Itoa(s.Tick) + " cannot be less than or equal to zero")
+	}
+	if len(s.Games) == 0 {
+		return errors.New("scenario must have at least one Game")
+	}
+	for i := range s.Games {
+		if s.Games[i].ID == 0 {
+			return errors.New(`game "` + s.Games[i].Name + `" must have a non-zero ID`)
+		}
+		if len(s.Games[i].Phases) == 0 {
+			return errors.New("game " + strconv.FormatUint(s.Games[i].ID, 10) + " must have at least one phase")
+		}
+		for _, p := range s.Games[i].Phases {
+			if _, err := status(p.Status); err != nil {
+				return errors.New("game " + strconv.FormatUint(s.Games[i].ID, 10) + ": " + err.Error())
+			}
+		}
+	}
+	return nil
+}
+func (m *mock) offense() []*team {
+	var o []*team
+	for _, t := range m.board.Teams {
+		if t.Offense {
+			o = append(o, t)
+		}
+	}
+	return o
+}
+func newMock(g gameScenario, n time.Time) *mock {
+	m := &mock{
+		begin:  n,
+		events: g.Events,
+		phases: g.Phases,
+		meta:   meta{ID: g.ID, Name: g.Name, Mode: g.Mode},
+		board: board{
+			Name:    g.Name,
+			Mode:    g.Mode,
+			Credit:  g.Credit,
+			Message: g.Message,
+			Teams:   make([]*team, 0, len(g.Teams)),
+			Events:  make([]event, 0),
+		},
+	}
+	m.meta.Status, _ = status(g.Phases[0].Status)
+	for _, t := range g.Teams {
+		v := &team{
+			ID:      t.ID,
+			Name:    t.Name,
+			Logo:    t.Logo,
+			Color:   t.Color,
+			Minimal: t.Minimal,
+			Offense: t.Offense,
+			Beacons: make([]beacon, 0),
+			Hosts:   make([]*host, 0, len(t.Hosts)),
+			Score:   score{Health: 100},
+		}
+		for _, h := range t.Hosts {
+			o := &host{ID: h.ID, Name: h.Name, Online: true, Services: make([]*service, 0, len(h.Services))}
+			for _, s := range h.Services {
+				o.Services = append(o.Services, &service{
+					ID:       s.ID,
+					Port:     s.Port,
+					Bonus:    s.Bonus,
+					State:    states[0],
+					Protocol: s.Protocol,
+				})
+			}
+			v.Hosts = append(v.Hosts, o)
+		}
+		m.board.Teams = append(m.board.Teams, v)
+	}
+	if m.meta.Status == running {
+		m.meta.Start = n
+	}
+	return m
+}
+
+// advance moves the Game to the next phase once the current phase duration has passed.
+// The last phase (or any phase with a zero duration) lasts forever.
+func (m *mock) advance(n time.Time) bool {
+	p := m.phases[m.phase]
+	if p.Duration <= 0 || m.phase+1 >= len(m.phases) || n.Sub(m.begin) < time.Duration(p.Duration)*time.Second {
+		return false
+	}
+	m.phase, m.begin = m.phase+1, n
+	m.meta.Status, _ = status(m.phases[m.phase].Status)
+	switch m.meta.Status {
+	case running:
+		if m.meta.Start.IsZero() {
+			m.meta.Start = n
+		}
+	case completed, cancelled:
+		if m.meta.End.IsZero() {
+			m.meta.End = n
+		}
+	}
+	return true
+}
+func (m *mock) step(r *rand.Rand, c chance, n time.Time) {
+	if m.meta.Status != running {
+		return
+	}
+	e := int(n.Sub(m.meta.Start).Seconds())
+	m.board.Events = m.board.Events[:0]
+	for _, v := range m.events {
+		if e < v.At || (v.Duration > 0 && e >= v.At+v.Duration) {
+			continue
+		}
+		m.board.Events = append(m.board.Events, event{ID: v.ID, Type: v.Type, Data: v.Data})
+	}
+	o := m.offense()
+	for _, t := range m.board.Teams {
+		if t.Offense {
+			continue
+		}
+		var g, a int64
+		for _, h := range t.Hosts {
+			if r.Float64() < c.Offline {
+				h.Online = !h.Online
+			}
+			for _, s := range h.Services {
+				if r.Float64() < c.Flip {
+					s.State = states[r.Intn(len(states))]
+				}
+				if a++; h.Online && s.State == states[0] {
+					g++
+				}
+			}
+		}
+		if a > 0 {
+			t.Score.Health = g * 100 / a
+		}
+		if c.Score > 0 {
+			t.Score.Total += r.Int63n(c.Score+1) * t.Score.Health / 100
+		}
+		for i := 0; i < len(t.Beacons); {
+			if r.Float64() < c.Clean {
+				t.Beacons = append(t.Beacons[:i], t.Beacons[i+1:]...)
+				continue
+			}
+			i++
+		}
+		if len(o) > 0 && r.Float64() < c.Beacon {
+			x := o[r.Intn(len(o))]
+			m.beacon++
+			t.Beacons = append(t.Beacons, beacon{ID: m.beacon, Team: x.ID, Color: x.Color})
+		}
+		if r.Float64() < c.Flag {
+			t.Flags.Open++
+		}
+		if t.Flags.Open > 0 && r.Float64() < c.Flag {
+			if t.Flags.Open--; r.Intn(2) == 0 {
+				t.Flags.Lost++
+			} else {
+				t.Flags.Captured++
+			}
+		}
+		if r.Float64() < c.Ticket {
+			t.Tickets.Open++
+		}
+		if t.Tickets.Open > 0 && r.Float64() < c.Ticket {
+			t.Tickets.Open--
+			t.Tickets.Closed++
+		}
+	}
+	for _, x := range o {
+		var b int64
+		for _, t := range m.board.Teams {
+			for _, v := range t.Beacons {
+				if v.Team == x.ID {
+					b++
+				}
+			}
+		}
+		x.Score.Total += b
+	}
+}
@@ -24,6 +24,7 @@ import (
 	"strings"
 
 	"github.com/PurpleSec/logx"
+	"github.com/PvJScorebot/scorebot-scoreboard/scoreboard/game"
 )
 
 var version = "unknown"
@@ -58,7 +59,79 @@ const defaults = `{
         }
     },
     "timeout": 10,
-    "scorebot": "http://scorebot"
+    "workers": 4,
+    "state_dir": "",
+    "uptime_callouts": false,
+    "activity": {
+        "announce": [
+            "host",
+            "service",
+            "beacon",
+            "flag"
+        ],
+        "games": {}
+    },
+    "milestones": [
+        {
+            "type": "first_flag",
+            "title": "FIRST BLOOD!",
+            "text": "{{.Team}} has captured the first flag of the Game!",
+            "duration": 30,
+            "window": true
+        },
+        {
+            "type": "first_beacon",
+            "text": "{{.Other}} has the first beacon on {{.Team}}!",
+            "duration": 60,
+            "window": false
+        },
+        {
+            "type": "lead_change",
+            "text": "{{.Team}} has taken the lead from {{.Other}}!",
+            "duration": 60,
+            "window": false
+        },
+        {
+            "type": "full_outage",
+            "title": "TOTAL OUTAGE!",
+            "text": "{{.Team}} has lost every service!",
+            "duration": 30,
+            "window": true
+        },
+        {
+            "type": "zero_health",
+            "text": "{{.Team}} has run out of health!",
+            "duration": 60,
+            "window": false
+        }
+    ],
+    "ranking": {
+        "metric": "total",
+        "order": false
+    },
+    "history": {
+        "interval": 30,
+        "retention": 360
+    },
+    "record": {
+        "file": "",
+        "size": 64
+    },
+    "replay": {
+        "file": "",
+        "speed": 1
+    },
+    "scorebot": "http://scorebot",
+    "scorebot_auth": {
+        "headers": {},
+        "token": "",
+        "username": "",
+        "password": "",
+        "cert": "",
+        "key": "",
+        "ca": "",
+        "insecure": false
+    }
 }
 `
 const usage = `Scorebot Scoreboard v2.5
@@ -72,16 +145,46 @@ Usage of scoreboard:
   -c <file>                 Scorebot configuration file path.
   -d                        Print default configuration and exit.
   -V                        Print version string and exit.
-  -sbe <url>                Scorebot core address or URL (Required without "-c").
+  -sbe <url>                Scorebot core address or URL (Required without "-c" or "-replay").
+  -sbe-token <token>        Scorebot API bearer token.
+  -sbe-user <username>      Scorebot API basic auth username.
+  -sbe-pass <password>      Scorebot API basic auth password.
+  -sbe-cert <file>          Scorebot API TLS client certificate file.
+  -sbe-key <file>           Scorebot API TLS client key file.
+  -sbe-ca <file>            Scorebot API TLS CA bundle file.
+  -sbe-insecure             Skip Scorebot API TLS verification (Lab use only!).
   -assets <dir>             Scoreboard secondary assets override URL.
   -dir <directory>          Scoreboard HTML override directory path.
   -log <file>               Scoreboard log file path.
   -log-level <number [0-5]> Scoreboard logging level (Default 2).
   -tick <seconds>           Scorebot poll tate, in seconds (Default 5).
   -timeout <seconds>        Scoreboard request timeout, in seconds (Default 10).
+  -workers <number>         Max number of Games polled at once (Default 4).
+  -uptime-callouts          Show Host and Service uptime stats in the board callouts.
+  -activity <list>          Activity ticker item types, "host", "service", "beacon",
+                             "flag", "all" or "none" (Comma separated, Default "all").
+                             Per Game types can be set in the config file.
+  -rank <metric>            Team ranking metric, "total", "health" or "flags" (Default "total").
+  -rank-order               Display Teams in rank order instead of by ID.
+  -history-interval <secs>  Time between score history points, in seconds (Default 30).
+                             Zero disables the score history.
+  -history-keep <minutes>   Score history retention, in minutes (Default 360).
+                             Zero keeps the history while the Game is watched.
+  -state <dir>              Directory to save the last known Game data to. Saved data
+                             is shown (marked as stale) when Scorebot is unreachable.
+  -record <file>            Record Scorebot responses to gzip capture files. The start
+                             time is added to the file name of each capture file.
+  -record-size <MB>         Capture file size before a new file is started (Default 64).
+  -replay <glob>            Serve the Scoreboard from capture files instead of Scorebot.
+                             Twitter and recording are disabled when replaying.
+  -replay-speed <number>    Replay speed multiplier (Default 1).
   -bind <socket>            Address and port to listen on (Default "0.0.0.0:8080").
   -cert <file>              Path to TLS certificate file.
   -key <file>               Path to TLS key file.
+  -ingest-key <key>         Bearer token for the Scorebot push endpoint "/ingest/<id>".
+                             The endpoint is disabled if empty.
+  -admin-key <key>          Bearer token for the replay control endpoint "/admin/replay".
+                             The endpoint is disabled if empty.
   -tw-ck <key>              Twitter Consumer API key.
   -tw-cs <secret>           Twitter Consumer API secret.
   -tw-ak <key>              Twitter Access API key.
@@ -114,6 +217,27 @@ type log struct {
 	File  string `json:"file,omitempty"`
 	Level int    `json:"level"`
 }
+type record struct {
+	File string `json:"file,omitempty"`
+	Size int    `json:"size"`
+}
+type rank struct {
+	Metric string `json:"metric"`
+	Order  bool   `json:"order"`
+}
+type activity struct {
+	Announce []string            `json:"announce"`
+	Games    map[string][]string `json:"games,omitempty"`
+}
+type rules []game.Milestone
+type history struct {
+	Interval  int `json:"interval"`
+	Retention int `json:"retention"`
+}
+type replay struct {
+	File  string  `json:"file,omitempty"`
+	Speed float64 `json:"speed"`
+}
 type creds struct {
 	AccessKey      string `json:"access_key"`
 	ConsumerKey    string `json:"consumer_key"`
@@ -126,16 +250,28 @@ type tweets struct {
 	Expire      int    `json:"expire"`
 }
 type config struct {
-	Scorebot  string `json:"scorebot"`
-	Key       string `json:"key,omitempty"`
-	Cert      string `json:"cert,omitempty"`
-	Directory string `json:"dir,omitempty"`
-	Assets    string `json:"assets"`
-	Listen    string `json:"listen"`
-	Log       log    `json:"log,omitempty"`
-	Twitter   tweets `json:"twitter,omitempty"`
-	Timeout   int    `json:"timeout"`
-	Tick      int    `json:"tick"`
+	Scorebot  string    `json:"scorebot"`
+	Auth      game.Auth `json:"scorebot_auth,omitempty"`
+	Key       string    `json:"key,omitempty"`
+	Ingest    string    `json:"ingest_key,omitempty"`
+	Admin     string    `json:"admin_key,omitempty"`
+	Cert      string    `json:"cert,omitempty"`
+	Directory string    `json:"dir,omitempty"`
+	State     string    `json:"state_dir,omitempty"`
+	Assets    string    `json:"assets"`
+	Listen    string    `json:"listen"`
+	Log       log       `json:"log,omitempty"`
+	Record    record    `json:"record,omitempty"`
+	History   history   `json:"history"`
+	Ranking   rank      `json:"ranking"`
+	Callouts  bool      `json:"uptime_callouts"`
+	Activity  activity  `json:"activity"`
+	Rules     rules     `json:"milestones,omitempty"`
+	Replay    replay    `json:"replay,omitempty"`
+	Twitter   tweets    `json:"twitter,omitempty"`
+	Timeout   int       `json:"timeout"`
+	Workers   int       `json:"workers"`
+	Tick      int       `json:"tick"`
 	twitter   bool
 }
 type filter struct {
@@ -172,6 +308,26 @@ func (c *config) verify() error {
 	if c.Timeout <= 0 {
 		return &errval{s: "timeout " + strconv.Itoa(c.Timeout) + " cannot be less than or equal to zero"}
 	}
+	if c.Workers <= 0 {
+		return &errval{s: "workers " + strconv.Itoa(c.Workers) + " cannot be less than or equal to zero"}
+	}
+	if len(c.Record.File) > 0 && c.Record.Size < 0 {
+		return &errval{s: "record size " + strconv.Itoa(c.Record.Size) + " cannot be less than zero"}
+	}
+	if c.History.Interval < 0 || c.History.Retention < 0 {
+		return &errval{s: "history interval and retention cannot be less than zero"}
+	}
+	if len(c.Scorebot) == 0 && len(c.Replay.File) == 0 {
+		return &errval{s: "scorebot cannot be empty"}
+	}
+	if len(c.Replay.File) > 0 {
+		if c.Replay.Speed <= 0 {
+			return &errval{s: "replay speed " + strconv.FormatFloat(c.Replay.Speed, 'f', -1, 64) + " cannot be less than or equal to zero"}
+		}
+		if len(c.Record.File) > 0 {
+			return &errval{s: "record and replay cannot be used at the same time"}
+		}
+	}
 	if c.Log.Level < int(logx.Trace) || c.Log.Level > int(logx.Fatal) {
 		return &errval{s: "log level " + strconv.Itoa(c.Tick) + "  must be between zero and five"}
 	}
@@ -187,7 +343,11 @@ func (c *config) verify() error {
 	if len(c.Twitter.Credentials.ConsumerKey) == 0 || len(c.Twitter.Credentials.ConsumerSecret) == 0 {
 		c.twitter = false
 	}
-	if c.twitter && c.Twitter.Expire <= 0 {
+	if c.twitter && len(c.Replay.File) > 0 {
+		// NOTE(dij): Replays use the Tweets from the capture instead.
+		c.twitter = false
+	}
+	if (c.twitter || len(c.Replay.File) > 0) && c.Twitter.Expire <= 0 {
 		return &errval{s: "tweet expire time " + strconv.Itoa(c.Timeout) + " cannot be less than or equal to zero"}
 	}
 	return nil
@@ -202,6 +362,7 @@ func Cmdline() (*Scoreboard, error) {
 		c                     config
 		args                  = flag.NewFlagSet("Scorebot Scoreboard", flag.ExitOnError)
 		d, ver                bool
+		act                   string
 		twbWords, twoUsers    string
 		s, twk, twl, twbUsers string
 	)
@@ -213,15 +374,36 @@ func Cmdline() (*Scoreboard, error) {
 	args.BoolVar(&d, "d", false, "")
 	args.BoolVar(&ver, "V", false, "")
 	args.StringVar(&c.Scorebot, "sbe", "", "")
+	args.StringVar(&c.Auth.Token, "sbe-token", "", "")
+	args.StringVar(&c.Auth.Username, "sbe-user", "", "")
+	args.StringVar(&c.Auth.Password, "sbe-pass", "", "")
+	args.StringVar(&c.Auth.Cert, "sbe-cert", "", "")
+	args.StringVar(&c.Auth.Key, "sbe-key", "", "")
+	args.StringVar(&c.Auth.CA, "sbe-ca", "", "")
+	args.BoolVar(&c.Auth.Insecure, "sbe-insecure", false, "")
 	args.StringVar(&c.Assets, "assets", "", "")
 	args.StringVar(&c.Directory, "dir", "", "")
 	args.StringVar(&c.Log.File, "log", "", "")
 	args.IntVar(&c.Log.Level, "log-level", 2, "")
 	args.IntVar(&c.Tick, "tick", 5, "")
 	args.IntVar(&c.Timeout, "timeout", 10, "")
+	args.IntVar(&c.Workers, "workers", 4, "")
+	args.StringVar(&c.State, "state", "", "")
+	args.BoolVar(&c.Callouts, "uptime-callouts", false, "")
+	args.StringVar(&act, "activity", "all", "")
+	args.StringVar(&c.Ranking.Metric, "rank", "total", "")
+	args.BoolVar(&c.Ranking.Order, "rank-order", false, "")
+	args.IntVar(&c.History.Interval, "history-interval", 30, "")
+	args.IntVar(&c.History.Retention, "history-keep", 360, "")
+	args.StringVar(&c.Record.File, "record", "", "")
+	args.IntVar(&c.Record.Size, "record-size", 64, "")
+	args.StringVar(&c.Replay.File, "replay", "", "")
+	args.Float64Var(&c.Replay.Speed, "replay-speed", 1, "")
 	args.StringVar(&c.Listen, "bind", "0.0.0.0:8080", "")
 	args.StringVar(&c.Key, "key", "", "")
 	args.StringVar(&c.Cert, "cert", "", "")
+	args.StringVar(&c.Ingest, "ingest-key", "", "")
+	args.StringVar(&c.Admin, "admin-key", "", "")
 	args.StringVar(&c.Twitter.Credentials.ConsumerKey, "tw-ck", "", "")
 	args.StringVar(&c.Twitter.Credentials.ConsumerSecret, "tw-cs", "", "")
 	args.StringVar(&c.Twitter.Credentials.AccessKey, "tw-ak", "", "")
@@ -245,10 +427,11 @@ func Cmdline() (*Scoreboard, error) {
 		os.Stdout.WriteString(defaults)
 		return nil, nil
 	}
-	if len(s) == 0 && len(c.Scorebot) == 0 {
+	if len(s) == 0 && len(c.Scorebot) == 0 && len(c.Replay.File) == 0 {
 		os.Stdout.WriteString(usage)
 		return nil, flag.ErrHelp
 	}
+	c.Activity.Announce = split(act)
 	c.Twitter.Filter.OnlyUsers = split(twoUsers)
 	c.Twitter.Filter.Language, c.Twitter.Filter.Keywords = split(twl), split(twk)
 	c.Twitter.Filter.BlockedUsers, c.Twitter.Filter.BlockedWords = split(twbUsers), split(twbWords)
@@ -0,0 +1,194 @@
+// Copyright(C) 2020 - 2023 iDigitalFlame
+//
+// This program is free software: you can redistribute it and / or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, either version 3 of the License, or
+// any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.If not, see <https://www.gnu.org/licenses/>.
+//
+
+package game
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// tickerSize is the number of recent activity items kept for each subscribed Game, which
+// are sent to new clients so their ticker is not empty.
+const tickerSize = 25
+
+const (
+	kindHost kinds = 1 << iota
+	kindService
+	kindBeacon
+	kindFlag
+
+	kindAll = kindHost | kindService | kindBeacon | kindFlag
+)
+
+// kinds is a set of the activity item types that are announced.
+type kinds uint8
+
+// notice is a single activity item created from a change in the Game state.
+type notice struct {
+	Text string `json:"text"`
+	Kind string `json:"kind"`
+	Team uint64 `json:"team"`
+	Time int64  `json:"time"`
+}
+
+// activity is the activity ticker configuration for all the Games in a Manager. Games
+// without an entry in the games map use the default set.
+type activity struct {
+	games map[string]kinds
+	all   kinds
+}
+
+func (k kinds) String() string {
+	switch k {
+	case kindHost:
+		return "host"
+	case kindService:
+		return "service"
+	case kindBeacon:
+		return "beacon"
+	case kindFlag:
+		return "flag"
+	}
+	return "Unknown"
+}
+func plural(n uint32, s string) string {
+	if n == 1 {
+		return "1 " + s
+	}
+	return strconv.FormatUint(uint64(n), 10) + " " + s + "s"
+}
+func parseKinds(v []string) (kinds, error) {
+	var k kinds
+	for _, s := range v {
+		switch strings.ToLower(strings.TrimSpace(s)) {
+		case "host", "hosts":
+			k |= kindHost
+		case "service", "services":
+			k |= kindService
+		case "beacon", "beacons":
+			k |= kindBeacon
+		case "flag", "flags":
+			k |= kindFlag
+		case "all":
+			k |= kindAll
+		case "none", "":
+		default:
+			return 0, errors.New(`invalid activity type "` + s + `"`)
+		}
+	}
+	return k, nil
+}
+
+// get returns the set of activity types announced for the Game. Games can be matched
+// by ID or by name.
+func (a activity) get(m meta) kinds {
+	if len(a.games) == 0 {
+		return a.all
+	}
+	if k, ok := a.games[strconv.FormatUint(m.ID, 10)]; ok {
+		return k
+	}
+	if k, ok := a.games[strings.ToLower(cleanSlugString(m.Name))]; ok {
+		return k
+	}
+	return a.all
+}
+
+// Notice adds an activity item to the planner if the type is being announced.
+func (p *planner) Notice(k kinds, t uint64, s string) {
+	if p.announce&k == 0 {
+		return
+	}
+	p.notices = append(p.notices, notice{Kind: k.String(), Team: t, Text: s})
+}
+func (t team) compareFlags(p *planner, o team) {
+	if t.Flags.Captured > o.Flags.Captured {
+		p.Notice(kindFlag, t.ID, t.Name+" captured "+plural(t.Flags.Captured-o.Flags.Captured, "flag"))
+	}
+	if t.Flags.Lost > o.Flags.Lost {
+		p.Notice(kindFlag, t.ID, t.Name+" lost "+plural(t.Flags.Lost-o.Flags.Lost, "flag"))
+	}
+}
+func (h host) compareOnline(p *planner, o host) {
+	switch {
+	case o.Online && !h.Online:
+		p.Notice(kindHost, p.team, p.names[p.team]+" host "+h.Name+" went offline")
+	case !o.Online && h.Online:
+		p.Notice(kindHost, p.team, p.names[p.team]+" host "+h.Name+" is back online")
+	}
+}
+func (s service) compareState(p *planner, o service) {
+	if o.State == s.State || (o.State != red && s.State != red) {
+		return
+	}
+	v := strconv.FormatUint(uint64(s.Port), 10) + "/" + s.Protocol.String() + " on " + p.host
+	if s.Protocol == icmp {
+		v = "ping on " + p.host
+	}
+	if s.State == red {
+		p.Notice(kindService, p.team, p.names[p.team]+" lost "+v)
+		return
+	}
+	p.Notice(kindService, p.team, p.names[p.team]+" restored "+v)
+}
+func (b beacon) compareNew(p *planner) {
+	if n, ok := p.names[b.Team]; ok {
+		p.Notice(kindBeacon, p.team, n+" has a new beacon on "+p.names[p.team])
+		return
+	}
+	p.Notice(kindBeacon, p.team, p.names[p.team]+" has a new beacon")
+}
+
+// ticker adds the activity items to the list of items waiting to be sent and the recent
+// items list. The caller must hold the subscription lock.
+func (s *subscription) ticker(n []notice, t int64) {
+	if len(n) == 0 {
+		return
+	}
+	for i := range n {
+		n[i].Time = t
+	}
+	s.notices = append(s.notices, n...)
+	if s.recent = append(s.recent, n...); len(s.recent) > tickerSize {
+		s.recent = append([]notice(nil), s.recent[len(s.recent)-tickerSize:]...)
+	}
+}
+
+// SetActivity sets the types of Game state changes that are announced to the clients as
+// activity items. The types can be "host", "service", "beacon", "flag", "all" or "none".
+// The default set is used for every Game without an entry in the games map, which can be
+// keyed by Game ID or name. This function must be called before 'Start'.
+func (m *Manager) SetActivity(d []string, g map[string][]string) error {
+	var err error
+	if m.activity.all, err = parseKinds(d); err != nil {
+		return err
+	}
+	if len(g) == 0 {
+		m.activity.games = nil
+		return nil
+	}
+	m.activity.games = make(map[string]kinds, len(g))
+	for n, v := range g {
+		k, err := parseKinds(v)
+		if err != nil {
+			return errors.New(`game "` + n + `": ` + err.Error())
+		}
+		m.activity.games[strings.ToLower(cleanSlugString(n))] = k
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+// Copyright(C) 2020 - 2023 iDigitalFlame
+//
+// This program is free software: you can redistribute it and / or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, either version 3 of the License, or
+// any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.If not, see <https://www.gnu.org/licenses/>.
+//
+
+package game
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net/http"
+	"os"
+)
+
+// Auth is a struct that contains the credentials and TLS trust options used when making
+// requests to Scorebot. Empty values are ignored.
+type Auth struct {
+	Headers  map[string]string `json:"headers,omitempty"`
+	Token    string            `json:"token,omitempty"`
+	Username string            `json:"username,omitempty"`
+	Password string            `json:"password,omitempty"`
+	Cert     string            `json:"cert,omitempty"`
+	Key      string            `json:"key,omitempty"`
+	CA       string            `json:"ca,omitempty"`
+	Insecure bool              `json:"insecure,omitempty"`
+}
+
+func (a *Auth) apply(r *http.Request) {
+	for k, v := range a.Headers {
+		r.Header.Set(k, v)
+	}
+	if len(a.Token) > 0 {
+		r.Header.Set("Authorization", "Bearer "+a.Token)
+	}
+	if len(a.Username) > 0 {
+		r.SetBasicAuth(a.Username, a.Password)
+	}
+}
+
+// SetAuth sets the credentials and TLS options used for Scorebot requests. This function
+// returns an error if the certificate or CA files cannot be loaded. This function must be
+// called before 'Start'.
+func (m *Manager) SetAuth(a Auth) error {
+	if (len(a.Cert) > 0) != (len(a.Key) > 0) {
+		return errors.New("client certificate and key must both be set")
+	}
+	t, ok := m.client.Transport.(*http.Transport)
+	if !ok {
+		return errors.New("client transport cannot be configured")
+	}
+	c := &tls.Config{MinVersion: tls.VersionTLS12, InsecureSkipVerify: a.Insecure}
+	if len(a.CA) > 0 {
+		b, err := os.ReadFile(a.CA)
+		if err != nil {
+			return errors.New(`unable to read CA bundle "` + a.CA + `": ` + err.Error())
+		}
+		c.RootCAs = x509.NewCertPool()
+		if !c.RootCAs.AppendCertsFromPEM(b) {
+			return errors.New(`no certificates could be loaded from CA bundle "` + a.CA + `"`)
+		}
+	}
+	if len(a.Cert) > 0 {
+		x, err := tls.LoadX509KeyPair(a.Cert, a.Key)
+		if err != nil {
+			return errors.New(`unable to load client certificate "` + a.Cert + `": ` + err.Error())
+		}
+		c.Certificates = []tls.Certificate{x}
+	}
+	t.TLSClientConfig, m.auth = c, a
+	return nil
+}
@@ -0,0 +1,63 @@
+// Copyright(C) 2020 - 2023 iDigitalFlame
+//
+// This program is free software: you can redistribute it and / or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, either version 3 of the License, or
+// any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.If not, see <https://www.gnu.org/licenses/>.
+//
+
+package game
+
+// backlogSize is the number of delta batches kept per subscription that can
+// be replayed to a reconnecting client before falling back to a full snapshot.
+const backlogSize = 64
+
+type batch struct {
+	Updates []update
+	Offense []update
+	Seq     uint64
+}
+type backlog struct {
+	e   [backlogSize]batch
+	pos int
+	n   int
+}
+
+func (b *backlog) add(s uint64, u, o []update) {
+	b.e[b.pos] = batch{Seq: s, Updates: u, Offense: o}
+	if b.pos = (b.pos + 1) % backlogSize; b.n < backlogSize {
+		b.n++
+	}
+}
+
+// since returns all the updates from the batches after the supplied sequence
+// number, in order. The offense view updates of each batch are included if f is
+// true. The boolean will be false if the backlog does not reach back far enough
+// to cover the requested sequence.
+func (b *backlog) since(s uint64, f bool) ([]update, bool) {
+	if b.n == 0 {
+		return nil, false
+	}
+	// Oldest entry is at 'pos' when the ring is full, otherwise at zero.
+	o := (b.pos - b.n + backlogSize) % backlogSize
+	if b.e[o].Seq > s+1 {
+		return nil, false
+	}
+	var r []update
+	for i := 0; i < b.n; i++ {
+		if v := b.e[(o+i)%backlogSize]; v.Seq > s {
+			if r = append(r, v.Updates...); f {
+				r = append(r, v.Offense...)
+			}
+		}
+	}
+	return r, true
+}
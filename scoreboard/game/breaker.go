@@ -0,0 +1,102 @@
+// Copyright(C) 2020 - 2023 iDigitalFlame
+//
+// This program is free software: you can redistribute it and / or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, either version 3 of the License, or
+// any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.If not, see <https://www.gnu.org/licenses/>.
+//
+
+package game
+
+import (
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+const (
+	// breakerLimit is the number of failed requests in a row before Scorebot is
+	// considered offline and the breaker opens.
+	breakerLimit = 3
+	breakerMin   = time.Second * 2
+	breakerMax   = time.Minute * 2
+)
+
+var errOffline = errors.New("scorebot is offline, waiting to retry")
+
+// breaker is a circuit breaker for the Scorebot requests. Once open, requests are
+// rejected until the backoff period passes, then a single request is allowed through
+// to probe if Scorebot is back. Each failed probe doubles the backoff period.
+type breaker struct {
+	next  time.Time
+	lock  sync.Mutex
+	wait  time.Duration
+	fails int
+	open  bool
+	probe bool
+}
+
+func (b *breaker) state() bool {
+	b.lock.Lock()
+	r := b.open
+	b.lock.Unlock()
+	return r
+}
+func (b *breaker) allow() bool {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if !b.open {
+		return true
+	}
+	if b.probe || time.Now().Before(b.next) {
+		return false
+	}
+	b.probe = true
+	return true
+}
+
+// success resets the breaker and returns true if it was open.
+func (b *breaker) success() bool {
+	b.lock.Lock()
+	r := b.open
+	b.fails, b.wait, b.open, b.probe = 0, 0, false, false
+	b.lock.Unlock()
+	return r
+}
+
+// release allows another probe request without counting the last one as a failure.
+// This is used when the request was cancelled by the caller and not by Scorebot.
+func (b *breaker) release() {
+	b.lock.Lock()
+	b.probe = false
+	b.lock.Unlock()
+}
+
+// failure records a failed request and returns true if this caused the breaker
+// to open.
+func (b *breaker) failure() bool {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.fails++
+	if b.probe = false; !b.open && b.fails < breakerLimit {
+		return false
+	}
+	if b.wait == 0 {
+		b.wait = breakerMin
+	} else if b.wait *= 2; b.wait > breakerMax {
+		b.wait = breakerMax
+	}
+	r := !b.open
+	// Equal jitter, wait somewhere between half and the full backoff period.
+	b.open, b.next = true, time.Now().Add(b.wait/2+time.Duration(rand.Int63n(int64(b.wait/2)+1)))
+	return r
+}
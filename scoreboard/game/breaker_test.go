@@ -0,0 +1,100 @@
+// Copyright(C) 2020 - 2023 iDigitalFlame
+//
+// This program is free software: you can redistribute it and / or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, either version 3 of the License, or
+// any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.If not, see <https://www.gnu.org/licenses/>.
+//
+
+package game
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/PurpleSec/logx"
+)
+
+func TestBreaker(t *testing.T) {
+	var b breaker
+	for i := 1; i < breakerLimit; i++ {
+		if b.failure() || !b.allow() {
+			t.Fatalf("Breaker opened after %d failures", i)
+		}
+	}
+	if !b.failure() || !b.state() {
+		t.Fatalf("Breaker did not open after %d failures", breakerLimit)
+	}
+	if b.allow() {
+		t.Fatalf("Breaker allowed a request before the backoff period passed")
+	}
+	w := b.wait
+	b.next = time.Now()
+	if !b.allow() {
+		t.Fatalf("Breaker did not allow a probe after the backoff period passed")
+	}
+	if b.allow() {
+		t.Fatalf("Breaker allowed a second request while probing")
+	}
+	if b.release(); !b.allow() {
+		t.Fatalf("Breaker did not allow a probe after the last one was released")
+	}
+	if b.failure() || b.wait != w*2 {
+		t.Fatalf("Breaker failed probe did not double the backoff period (%s, want %s)", b.wait, w*2)
+	}
+	if b.next = time.Now(); !b.allow() || !b.success() || b.state() || !b.allow() {
+		t.Fatalf("Breaker did not close after a successful probe")
+	}
+}
+func TestManagerBreakerCancel(t *testing.T) {
+	var (
+		d = make(chan struct{})
+		s = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			select {
+			case <-d:
+			case <-r.Context().Done():
+			}
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		}))
+	)
+	defer s.Close()
+	defer close(d)
+	m, err := New(s.URL, "", time.Hour, 5*time.Second, logx.NOP)
+	if err != nil {
+		t.Fatalf("New failed: %s", err)
+	}
+	// NOTE(dij): Simulate a slow tick, every request is cancelled by the parent
+	//            context before Scorebot answers.
+	x, f := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer f()
+	var g sync.WaitGroup
+	for i := 0; i < breakerLimit*4; i++ {
+		g.Add(1)
+		go func() {
+			defer g.Done()
+			if _, _, err := m.get(x, "/api/games/"); err == nil {
+				t.Errorf("Request did not fail after the context was cancelled")
+			}
+		}()
+	}
+	if g.Wait(); m.breaker.state() {
+		t.Fatalf("Breaker opened from cancelled requests")
+	}
+	y, c := context.WithCancel(context.Background())
+	c()
+	if _, _, err := m.get(y, "/api/games/"); err == nil || m.breaker.fails != 0 {
+		t.Fatalf("Request cancelled before it was sent counted against the breaker")
+	}
+}
@@ -0,0 +1,107 @@
+// Copyright(C) 2020 - 2023 iDigitalFlame
+//
+// This program is free software: you can redistribute it and / or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, either version 3 of the License, or
+// any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.If not, see <https://www.gnu.org/licenses/>.
+//
+
+package game
+
+import (
+	"net/http"
+	"sync"
+	"sync/atomic"
+)
+
+// cache holds the last response for each Scorebot URL, which is used to send
+// conditional requests and to detect byte-identical bodies.
+type cache struct {
+	e        map[string]*response
+	lock     sync.Mutex
+	total    uint64
+	modified uint64
+	same     uint64
+}
+type response struct {
+	etag     string
+	modified string
+	body     []byte
+	sum      uint64
+}
+
+// CacheStats is a snapshot of the Scorebot request cache counters.
+type CacheStats struct {
+	Requests    uint64 `json:"requests"`
+	NotModified uint64 `json:"not_modified"`
+	Unchanged   uint64 `json:"unchanged"`
+}
+
+func (c *cache) stats() CacheStats {
+	return CacheStats{
+		Requests:    atomic.LoadUint64(&c.total),
+		NotModified: atomic.LoadUint64(&c.modified),
+		Unchanged:   atomic.LoadUint64(&c.same),
+	}
+}
+func (c *cache) remove(u string) {
+	c.lock.Lock()
+	delete(c.e, u)
+	c.lock.Unlock()
+}
+func (c *cache) prepare(u string, r *http.Request) *response {
+	atomic.AddUint64(&c.total, 1)
+	c.lock.Lock()
+	v := c.e[u]
+	c.lock.Unlock()
+	if v == nil {
+		return nil
+	}
+	if len(v.etag) > 0 {
+		r.Header.Set("If-None-Match", v.etag)
+	}
+	if len(v.modified) > 0 {
+		r.Header.Set("If-Modified-Since", v.modified)
+	}
+	return v
+}
+
+// HitRate returns the percentage of requests that did not require parsing a new body,
+// either from a 'Not Modified' response or a byte-identical body.
+func (s CacheStats) HitRate() float64 {
+	if s.Requests == 0 {
+		return 0
+	}
+	return float64(s.NotModified+s.Unchanged) * 100 / float64(s.Requests)
+}
+
+// store saves the response for the URL and returns the hash of the body and true if
+// the body is different from the last one.
+func (c *cache) store(u string, o *http.Response, b []byte) (uint64, bool) {
+	h := updateFnv(fnvStart, b)
+	if h == 0 {
+		// Zero is used to mean "no sum", so make sure we never return it.
+		h = 1
+	}
+	c.lock.Lock()
+	v, ok := c.e[u]
+	if ok = ok && v.sum == h; ok {
+		atomic.AddUint64(&c.same, 1)
+	}
+	c.e[u] = &response{
+		sum:      h,
+		body:     b,
+		etag:     o.Header.Get("ETag"),
+		modified: o.Header.Get("Last-Modified"),
+	}
+	c.lock.Unlock()
+	return h, !ok
+}
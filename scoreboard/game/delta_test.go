@@ -0,0 +1,457 @@
+// Copyright(C) 2020 - 2023 iDigitalFlame
+//
+// This program is free software: you can redistribute it and / or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, either version 3 of the License, or
+// any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.If not, see <https://www.gnu.org/licenses/>.
+//
+
+package game
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+var golden = flag.Bool("update", false, "Update the golden files in testdata.")
+
+// board is a model of how the client applies updates (see 'handle_update' in
+// scoreboard.js), used to check that the create and delta lists agree.
+type board struct {
+	elements map[string]*element
+	events   map[string]update
+}
+type element struct {
+	Attrs map[string]string
+	Class map[string]bool
+	Text  string
+}
+type snapshot struct {
+	Name   string
+	Before string
+	After  string
+}
+
+func testSnapshots() []snapshot {
+	b := testGame(1)
+	return []snapshot{
+		{Name: "initial", After: b},
+		{Name: "unchanged", Before: b, After: b},
+		{Name: "score", Before: b, After: testGame(2)},
+		{Name: "message", Before: b, After: strings.Replace(b, `"message":"Hello"`, `"message":"Goodbye"`, 1)},
+		{Name: "service", Before: b, After: strings.Replace(b, `"status":"red"`, `"status":"green"`, 1)},
+		{Name: "host-offline", Before: b, After: strings.Replace(b, `"online":true`, `"online":false`, 1)},
+		{
+			Name:   "flags",
+			Before: b,
+			After:  strings.Replace(b, `"flags":{"open":1,"lost":0,"captured":0}`, `"flags":{"open":0,"lost":1,"captured":2}`, 1),
+		},
+		{
+			Name:   "beacons",
+			Before: b,
+			After: strings.Replace(
+				strings.Replace(b, `"beacons":[{"id":1,"team":1,"color":"#FF0000"}]`, `"beacons":[]`, 1),
+				`"beacons":[]`, `"beacons":[{"id":3,"team":2,"color":"#00FF00"},{"id":2,"team":2,"color":"#00FF00"}]`, 1,
+			),
+		},
+		{
+			Name:   "services",
+			Before: b,
+			After: strings.Replace(b,
+				`"services":[{"id":1,"port":80,"status":"green","protocol":"tcp"}]`,
+				`"services":[{"id":4,"port":443,"status":"yellow","protocol":"tcp","bool":true},{"id":3,"port":0,"status":"green","protocol":"icmp"}]`, 1,
+			),
+		},
+		{
+			Name:   "teams",
+			Before: b,
+			After: strings.Replace(b, `{"id":1,"name":"Blue-1"`,
+				`{"id":4,"name":"Red","logo":"red.png","color":"#FF0000","offense":true,"score":{"total":0,"health":0},"hosts":[],"beacons":[]},`+
+					`{"id":3,"name":"Blue-3","logo":"","color":"#0000AA","minimal":true,"hosts":[{"id":9,"name":"db","online":true,"services":[]}],"beacons":[]},`+
+					`{"id":1,"name":"Blue-1"`, 1,
+			),
+		},
+		{
+			Name:   "events",
+			Before: strings.Replace(b, `"events":[]`, `"events":[{"id":1,"type":0,"data":{"text":"One"}},{"id":2,"type":0,"data":{"text":"Two"}}]`, 1),
+			After:  strings.Replace(b, `"events":[]`, `"events":[{"id":3,"type":0,"data":{"text":"Three"}},{"id":2,"type":0,"data":{"text":"Two"}}]`, 1),
+		},
+	}
+}
+func testDelta(t *testing.T, v snapshot) []byte {
+	var o *game
+	if len(v.Before) > 0 {
+		o = new(game)
+		if err := json.Unmarshal([]byte(v.Before), o); err != nil {
+			t.Fatalf("Unmarshal %q failed: %s", v.Name, err)
+		}
+		o.Meta.ID = 1
+		o.Delta("/assets/", nil)
+	}
+	n := new(game)
+	if err := json.Unmarshal([]byte(v.After), n); err != nil {
+		t.Fatalf("Unmarshal %q failed: %s", v.Name, err)
+	}
+	n.Meta.ID = 1
+	c, d := n.Delta("/assets/", o)
+	b, err := json.MarshalIndent(struct {
+		Delta  []update `json:"delta"`
+		Create []update `json:"create"`
+	}{d, c}, "", "\t")
+	if err != nil {
+		t.Fatalf("Marshal %q failed: %s", v.Name, err)
+	}
+	return append(b, '\n')
+}
+func TestDeltaGolden(t *testing.T) {
+	for _, v := range testSnapshots() {
+		t.Run(v.Name, func(t *testing.T) {
+			var (
+				b = testDelta(t, v)
+				f = filepath.Join("testdata", "delta", v.Name+".json")
+			)
+			for i := 0; i < 10; i++ {
+				if !bytes.Equal(b, testDelta(t, v)) {
+					t.Fatalf("Delta %q did not return the same updates", v.Name)
+				}
+			}
+			if *golden {
+				if err := os.MkdirAll(filepath.Dir(f), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(f, b, 0644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			e, err := os.ReadFile(f)
+			if err != nil {
+				t.Fatalf("Could not read golden file (use -update to create it): %s", err)
+			}
+			if !bytes.Equal(b, e) {
+				t.Fatalf("Delta %q does not match %s:\n%s", v.Name, f, b)
+			}
+		})
+	}
+}
+func TestDeltaMilestoneEvent(t *testing.T) {
+	var (
+		a, b, c = testBoard(2, 1, 1), testBoard(2, 1, 1), testBoard(2, 1, 1)
+		e       = event{ID: localEvent | 1, Type: 1, Data: map[string]string{"text": "First Blood"}}
+	)
+	b.Events.Current = append(b.Events.Current, e)
+	x, _ := a.Delta("", nil)
+	v := newBoard(x)
+	_, d := b.Delta("", a)
+	if v.apply(d); len(v.events) != 1 {
+		t.Fatalf("Delta did not add the milestone event")
+	}
+	if x, _ = b.Delta("", nil); v.diff(newBoard(x)) != "" {
+		t.Fatalf("Delta milestone event does not match the create list: %s", v.diff(newBoard(x)))
+	}
+	_, d = c.Delta("", b)
+	if v.apply(d); len(v.events) != 0 {
+		t.Fatalf("Delta did not remove the milestone event")
+	}
+}
+func TestDeltaOffenseTargets(t *testing.T) {
+	// NOTE(dij): The beacons on both Teams are held by the other Team, and neither
+	//            is marked as offense, so every attacker is added by its beacons.
+	a, b := testBoard(2, 1, 1), testBoard(2, 1, 1)
+	b.Teams[0].Name = "Renamed"
+	a.Delta("", nil)
+	a.Offense(nil)
+	c, _ := b.Delta("", a)
+	for i := range c {
+		if strings.HasPrefix(c[i].ID, "game-offense") {
+			t.Fatalf("Delta should not add offense update %q to the board", c[i].ID)
+		}
+	}
+	_, d := b.Offense(a)
+	if !hasUpdate(d, "game-offense-a2-targets-d1-name", "", "Renamed") {
+		t.Fatalf("Delta did not rename the offense target in %+v", d)
+	}
+	if hasUpdate(d, "game-offense-a2-targets-d1-count", "", "1") {
+		t.Fatalf("Delta should not update the unchanged offense target count")
+	}
+}
+func TestDeltaUptime(t *testing.T) {
+	var (
+		i       = "game-team-t1-host-h1001"
+		a, b, c = testBoard(1, 1, 0), testBoard(1, 1, 0), testBoard(1, 1, 0)
+	)
+	a.Teams[0].Hosts[0].stat = uptime{Percent: 99.5, Since: 1000, MTTR: 60, Up: true, set: true}
+	b.Teams[0].Hosts[0].stat = uptime{Percent: 99.6, Since: 1000, MTTR: 60, Up: true, set: true}
+	c.Teams[0].Hosts[0].stat = uptime{Percent: 99.6, Since: 2000, MTTR: 60, set: true}
+	a.Delta("", nil)
+	_, d := b.Delta("", a)
+	if !hasUpdate(d, i, "data-uptime", "99.6") {
+		t.Fatalf("Delta did not send the changed uptime in %+v", d)
+	}
+	if hasUpdate(d, i, "data-since", "1000") || hasUpdate(d, i, "data-mttr", "60") {
+		t.Fatalf("Delta should not send the Since and MTTR without a state change")
+	}
+	if _, d = c.Delta("", b); hasUpdate(d, i, "data-uptime", "99.6") || !hasUpdate(d, i, "data-since", "2000") {
+		t.Fatalf("Delta should only send the changed Since in %+v", d)
+	}
+}
+func TestDeltaRankMove(t *testing.T) {
+	a, b, c := testBoard(2, 1, 1), testBoard(2, 1, 1), testBoard(2, 1, 1)
+	b.Teams[0].Score.Total, c.Teams[0].Score.Total = 300, 310
+	a.Delta("", nil)
+	if _, d := b.Delta("", a); !hasUpdate(d, "game-team-t1-name-rmove", "", "+1") {
+		t.Fatalf("Delta did not send the rank change in %+v", d)
+	}
+	x, d := c.Delta("", b)
+	if !hasUpdate(x, "game-team-t1-name-rmove", "", "+1") || hasUpdate(d, "game-team-t1-name-rmove", "", "=") {
+		t.Fatalf("Delta should keep the rank change until the rank changes again")
+	}
+}
+
+func newBoard(u []update) *board {
+	b := &board{elements: make(map[string]*element), events: make(map[string]update)}
+	for _, i := range []string{"game", "game-message", "game-status", "game-team", "game-tweet"} {
+		b.elements[i] = &element{Class: make(map[string]bool), Attrs: make(map[string]string)}
+	}
+	b.apply(u)
+	return b
+}
+func (b *board) apply(u []update) {
+	for _, v := range u {
+		b.update(v)
+	}
+}
+func (b *board) update(u update) {
+	if u.Event {
+		if u.Remove {
+			delete(b.events, u.ID)
+		} else {
+			b.events[u.ID] = u
+		}
+		return
+	}
+	if _, ok := b.elements[u.ID]; ok && u.Remove {
+		for k := range b.elements {
+			if k == u.ID || strings.HasPrefix(k, u.ID+"-") {
+				delete(b.elements, k)
+			}
+		}
+		return
+	}
+	e, ok := b.elements[u.ID]
+	if !ok {
+		i := strings.LastIndexByte(u.ID, '-')
+		if i <= 0 {
+			return
+		}
+		if _, ok := b.elements[u.ID[:i]]; !ok {
+			return
+		}
+		e = &element{Class: make(map[string]bool), Attrs: make(map[string]string)}
+		b.elements[u.ID] = e
+	}
+	if len(u.Class) > 0 {
+		e.Class = make(map[string]bool)
+		for _, c := range strings.Split(u.Class, " ") {
+			e.Class[c] = true
+		}
+	}
+	var v string
+	if u.Value != nil {
+		v = *u.Value
+	}
+	switch {
+	case len(v) == 0:
+	case len(u.Name) == 0:
+		e.Text = v
+	case u.Name == "class" && v[0] == '+':
+		e.Class[v[1:]] = true
+	case u.Name == "class" && v[0] == '-':
+		delete(e.Class, v[1:])
+	case u.Name == "class":
+		e.Class[v] = true
+	default:
+		e.Attrs[u.Name] = v
+	}
+}
+func (b *board) diff(o *board) string {
+	for k, v := range b.elements {
+		x, ok := o.elements[k]
+		if !ok {
+			return "element " + k + " is missing"
+		}
+		if !reflect.DeepEqual(v, x) {
+			return "element " + k + " differs: " + printStr(v.Text) + " " + printStr(x.Text)
+		}
+	}
+	for k := range o.elements {
+		if _, ok := b.elements[k]; !ok {
+			return "element " + k + " was not removed"
+		}
+	}
+	if !reflect.DeepEqual(b.events, o.events) {
+		return "events differ"
+	}
+	return ""
+}
+
+// checkDelta checks that applying the delta list to the board built from the old create
+// list is the same as the board built from the new create list.
+func checkDelta(t *testing.T, before, after *game) {
+	c, _ := before.Delta("", nil)
+	v, d := after.Delta("", before)
+	x, y := newBoard(c), newBoard(v)
+	if x.apply(d); x.diff(y) != "" {
+		t.Fatalf("Delta does not match the new create list: %s", x.diff(y))
+	}
+}
+func uniqueIDs(g *game) bool {
+	var (
+		t = make(map[uint64]bool, len(g.Teams))
+		e = make(map[uint64]bool, len(g.Events.Current))
+	)
+	for i := range g.Events.Current {
+		if e[g.Events.Current[i].ID] {
+			return false
+		}
+		e[g.Events.Current[i].ID] = true
+	}
+	for i := range g.Teams {
+		if t[g.Teams[i].ID] {
+			return false
+		}
+		t[g.Teams[i].ID] = true
+		var (
+			h = make(map[uint64]bool, len(g.Teams[i].Hosts))
+			b = make(map[uint64]bool, len(g.Teams[i].Beacons))
+		)
+		for _, v := range g.Teams[i].Beacons {
+			if b[v.ID] {
+				return false
+			}
+			b[v.ID] = true
+		}
+		for _, v := range g.Teams[i].Hosts {
+			if h[v.ID] {
+				return false
+			}
+			h[v.ID] = true
+			s := make(map[uint64]bool, len(v.Services))
+			for _, x := range v.Services {
+				if s[x.ID] {
+					return false
+				}
+				s[x.ID] = true
+			}
+		}
+	}
+	return true
+}
+
+// mutate makes random changes to the Game, like the ones Scorebot makes between ticks.
+func mutate(g *game, r *rand.Rand) {
+	for n := r.Intn(5) + 1; n > 0; n-- {
+		if len(g.Teams) == 0 {
+			g.Teams = append(g.Teams, team{ID: uint64(r.Intn(10) + 1), Name: "New"})
+			continue
+		}
+		t := &g.Teams[r.Intn(len(g.Teams))]
+		switch r.Intn(12) {
+		case 0:
+			g.Message = "Message " + strconv.Itoa(r.Intn(3))
+		case 1:
+			t.Score.Total += int64(r.Intn(100))
+			t.Score.Health = int64(r.Intn(100))
+		case 2:
+			t.Flags.Captured++
+			t.Tickets.Open = uint32(r.Intn(3))
+		case 3:
+			t.Offense, t.Minimal = !t.Offense, r.Intn(2) == 0
+		case 4:
+			t.Beacons = append(t.Beacons, beacon{ID: uint64(100 + r.Intn(1000)), Team: g.Teams[r.Intn(len(g.Teams))].ID, Color: "#ff0000"})
+		case 5:
+			if len(t.Beacons) > 0 {
+				t.Beacons = t.Beacons[1:]
+			}
+		case 6:
+			if len(t.Hosts) > 0 {
+				h := &t.Hosts[r.Intn(len(t.Hosts))]
+				h.Online = !h.Online
+			}
+		case 7:
+			if len(t.Hosts) > 0 {
+				if h := &t.Hosts[r.Intn(len(t.Hosts))]; len(h.Services) > 0 {
+					h.Services[r.Intn(len(h.Services))].State = state(r.Intn(3))
+				}
+			}
+		case 8:
+			if len(t.Hosts) > 0 {
+				i := r.Intn(len(t.Hosts))
+				t.Hosts = append(t.Hosts[:i], t.Hosts[i+1:]...)
+			}
+		case 9:
+			t.Hosts = append(t.Hosts, host{ID: uint64(100 + r.Intn(1000)), Name: "new", Online: true, Services: []service{
+				{ID: uint64(1000 + r.Intn(1000)), Port: 22, State: state(r.Intn(3))},
+			}})
+		case 10:
+			g.Events.Current = append(g.Events.Current, event{ID: uint64(10 + r.Intn(100)), Data: map[string]string{"text": "New Event"}})
+		case 11:
+			i := r.Intn(len(g.Teams))
+			g.Teams = append(g.Teams[:i], g.Teams[i+1:]...)
+		}
+	}
+}
+func TestDeltaProperty(t *testing.T) {
+	c := testCorpus(t)
+	for i := range c {
+		for x := range c {
+			var a, b game
+			if err := json.Unmarshal(c[i], &a); err != nil {
+				t.Fatalf("Unmarshal failed: %s", err)
+			}
+			if err := json.Unmarshal(c[x], &b); err != nil {
+				t.Fatalf("Unmarshal failed: %s", err)
+			}
+			checkDelta(t, &a, &b)
+		}
+	}
+	r := rand.New(rand.NewSource(49))
+	for i := 0; i < 500; i++ {
+		var a, b game
+		json.Unmarshal(c[r.Intn(len(c))], &a)
+		json.Unmarshal(c[r.Intn(len(c))], &b)
+		if mutate(&b, r); !uniqueIDs(&b) {
+			continue
+		}
+		checkDelta(t, &a, &b)
+	}
+}
+func FuzzDelta(f *testing.F) {
+	c := testCorpus(f)
+	for i := 1; i < len(c); i++ {
+		f.Add(c[i-1], c[i])
+	}
+	f.Fuzz(func(t *testing.T, x, y []byte) {
+		var a, b game
+		if json.Unmarshal(x, &a) != nil || json.Unmarshal(y, &b) != nil || !uniqueIDs(&a) || !uniqueIDs(&b) {
+			return
+		}
+		checkDelta(t, &a, &b)
+	})
+}
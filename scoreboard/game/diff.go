@@ -0,0 +1,444 @@
+// Copyright(C) 2020 - 2023 iDigitalFlame
+//
+// This program is free software: you can redistribute it and / or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, either version 3 of the License, or
+// any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.If not, see <https://www.gnu.org/licenses/>.
+//
+
+package game
+
+import (
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unsafe"
+)
+
+// The diff engine hashes and compares the Game model types using the "sb" struct tags,
+// which map the fields to the board elements. A tag can hold multiple entries split by
+// a semicolon, each entry is a comma separated list that starts with the entry type:
+//
+//	key,<id prefix>,<class>            The item ID. Items are placed in a container
+//	                                   element with the prefix and ID as the element
+//	                                   ID, which is also used as the item prefix.
+//	value,<id>,<class>                 Set the element text to the field value. If
+//	                                   the class starts with "@", the class is taken
+//	                                   from the named field instead.
+//	property,<id>,<name>[,<format>]    Set the element property to the field value.
+//	                                   Any "%s" in the format is replaced with it.
+//	class,<id>,[!]<class>              Add the class to the element if the field is
+//	                                   true (or false with "!") and remove it if not.
+//	element,<id>,<class>               Create an empty element, used on blank fields.
+//	nested                             A struct field that is compared by its own hash.
+//	children                           A slice of keyed items.
+//	hash                               Only add the field to the item hash.
+//
+// Every tagged type must have "hash" and "pos" fields. The position of the first update
+// of the item in the create list is stored in the pos field, so the next Delta can take
+// the element IDs of the same item from the last create list instead of building them,
+// as the IDs only change with the key of the item.
+//
+// Items with a different hash (or that are new) send all their values as changes,
+// otherwise they are only added to the values used for new clients. The total of an
+// item is a tree hash of it and everything below it, if it is the same (and the keys of
+// the children are in the same positions) then the children are compared by position,
+// otherwise they are matched by their keys.
+//
+// Types can implement the 'extra' interface to add updates that cannot be described
+// with tags, which is called after the fields and before the children of the item.
+const (
+	tagValue uint8 = iota
+	tagProperty
+	tagClass
+	tagElement
+)
+
+var schemas sync.Map
+
+type tag struct {
+	id     string
+	arg    string
+	format string
+	on     string
+	off    string
+	index  int
+	class  int
+	kind   uint8
+	invert bool
+	str    bool
+}
+type schema struct {
+	prefix   string
+	class    string
+	tags     []tag
+	hashed   []int
+	nested   []int
+	children []int
+	key      int
+	hash     int
+	total    int
+	pos      int
+	strs     uint64
+	extra    bool
+	prepare  bool
+}
+
+// extra is implemented by model types that have updates that cannot be described with
+// tags. The old value is a pointer of the same type as the receiver and points to an
+// empty item for new items.
+type extra interface {
+	extra(p *planner, o interface{})
+}
+
+// preparer is implemented by model types that need to be changed before they are
+// hashed, such as sorting their children.
+type preparer interface {
+	prepare()
+}
+type classer interface {
+	class() string
+}
+
+func (s *schema) same(n, o reflect.Value) bool {
+	return o.Field(s.hash).Uint() == n.Field(s.hash).Uint()
+}
+func schemaOf(t reflect.Type) *schema {
+	if v, ok := schemas.Load(t); ok {
+		return v.(*schema)
+	}
+	s := &schema{key: -1, hash: -1, total: -1, pos: -1}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		// NOTE(dij): Stringer fields are hashed and printed using their String
+		//            function, the others are read directly from the value.
+		if f.Type.Implements(reflect.TypeOf((*stringer)(nil)).Elem()) {
+			s.strs |= 1 << uint(i)
+		}
+		switch f.Name {
+		case "hash":
+			s.hash = i
+			continue
+		case "total":
+			s.total = i
+			continue
+		case "pos":
+			s.pos = i
+			continue
+		}
+		v, ok := f.Tag.Lookup("sb")
+		if !ok {
+			continue
+		}
+		for _, e := range strings.Split(v, ";") {
+			a := strings.Split(e, ",")
+			for len(a) < 4 {
+				a = append(a, "")
+			}
+			x := tag{id: a[1], arg: a[2], format: a[3], index: i, class: -1, str: s.strs&(1<<uint(i)) != 0}
+			switch a[0] {
+			case "key":
+				s.key, s.prefix, s.class = i, a[1], a[2]
+				s.hashed = append(s.hashed, i)
+			case "value":
+				if len(x.arg) > 1 && x.arg[0] == '@' {
+					c, ok := t.FieldByName(x.arg[1:])
+					if !ok {
+						panic(`diff: unknown class field "` + x.arg[1:] + `" on "` + t.Name() + `"`)
+					}
+					x.class = c.Index[0]
+				}
+				s.tags, s.hashed = append(s.tags, x), append(s.hashed, i)
+			case "property":
+				x.kind = tagProperty
+				s.tags, s.hashed = append(s.tags, x), append(s.hashed, i)
+			case "class":
+				if x.kind = tagClass; len(x.arg) > 0 && x.arg[0] == '!' {
+					x.arg, x.invert = x.arg[1:], true
+				}
+				x.on, x.off = "+"+x.arg, "-"+x.arg
+				s.tags, s.hashed = append(s.tags, x), append(s.hashed, i)
+			case "element":
+				x.kind = tagElement
+				s.tags = append(s.tags, x)
+			case "nested":
+				s.nested = append(s.nested, i)
+			case "children":
+				s.children = append(s.children, i)
+			case "hash":
+				s.hashed = append(s.hashed, i)
+			default:
+				panic(`diff: unknown tag "` + a[0] + `" on "` + t.Name() + "." + f.Name + `"`)
+			}
+		}
+	}
+	if s.hash < 0 || s.pos < 0 {
+		panic(`diff: type "` + t.Name() + `" does not have a hash or pos field`)
+	}
+	p := reflect.PointerTo(t)
+	s.extra, s.prepare = p.Implements(reflect.TypeOf((*extra)(nil)).Elem()), p.Implements(reflect.TypeOf((*preparer)(nil)).Elem())
+	// NOTE(dij): Fields that are tagged more than once should only be hashed once.
+	if len(s.hashed) > 1 {
+		k := s.hashed[:1]
+		for _, i := range s.hashed[1:] {
+			if i != k[len(k)-1] {
+				k = append(k, i)
+			}
+		}
+		s.hashed = k
+	}
+	v, _ := schemas.LoadOrStore(t, s)
+	return v.(*schema)
+}
+func field(v reflect.Value, i int) *uint64 {
+	return (*uint64)(unsafe.Pointer(v.Field(i).UnsafeAddr()))
+}
+
+// reuse returns the updates of the old item from the last create list, which start with
+// the container of the item (if the item is keyed) followed by the elements of each tag,
+// or nil if the old item is not the same item or has no position. The position of the
+// new item is set to where its updates will be added. Both items must be addressable.
+func (s *schema) reuse(p *planner, n, o reflect.Value) []update {
+	var (
+		i = *field(o, s.pos)
+		c = len(s.tags)
+	)
+	if *field(n, s.pos) = uint64(len(p.Create)) + 1; s.key >= 0 {
+		if c++; o.Field(s.key).Uint() != n.Field(s.key).Uint() {
+			return nil
+		}
+	}
+	if i == 0 || i-1+uint64(c) > uint64(len(p.old)) {
+		return nil
+	}
+	return p.old[i-1 : i-1+uint64(c)]
+}
+
+// sum hashes the item and all of its nested items and children and returns the tree
+// hash of the item, which is stored as the total. The hash of the item is only computed
+// once, but the total is always updated. Items without a total return their own hash.
+// The value must be addressable.
+func sum(h *hasher, v reflect.Value) uint64 {
+	s := schemaOf(v.Type())
+	x := field(v, s.hash)
+	if *x == 0 {
+		if s.prepare {
+			v.Addr().Interface().(preparer).prepare()
+		}
+		for _, i := range s.hashed {
+			h.hashValue(v.Field(i), s.strs&(1<<uint(i)) != 0)
+		}
+		*x = h.Segment()
+	}
+	if s.total < 0 {
+		return *x
+	}
+	t := fold(fnvStart, *x)
+	for _, i := range s.nested {
+		t = fold(t, sum(h, v.Field(i)))
+	}
+	for _, i := range s.children {
+		c := v.Field(i)
+		// NOTE(dij): The length is added so children cannot move between lists
+		//            without changing the total.
+		t = fold(t, uint64(c.Len()))
+		for k := 0; k < c.Len(); k++ {
+			t = fold(t, sum(h, c.Index(k)))
+		}
+	}
+	*field(v, s.total) = t
+	return t
+}
+
+// diff compares the new and old items and adds the updates to the planner. Both items
+// must be addressable. If same is true, the values of the item are only added for new
+// clients.
+func diff(p *planner, n, o reflect.Value, same bool) {
+	var (
+		s = schemaOf(n.Type())
+		r = s.reuse(p, n, o)
+	)
+	if s.key >= 0 {
+		var i string
+		if r != nil {
+			i, r = r[0].ID, r[1:]
+		} else {
+			i = p.prefix + "-" + s.prefix + strconv.FormatUint(n.Field(s.key).Uint(), 10)
+		}
+		p.add(update{ID: i, Value: p.text(""), Class: s.class}, o.Field(s.key).Uint() == 0)
+		p.Prefix(i)
+	}
+	for i := range s.tags {
+		if r != nil {
+			s.tags[i].emit(p, n, r[i].ID, same)
+		} else {
+			s.tags[i].emit(p, n, p.join(s.tags[i].id), same)
+		}
+	}
+	for _, i := range s.nested {
+		diff(p, n.Field(i), o.Field(i), schemaOf(n.Field(i).Type()).same(n.Field(i), o.Field(i)))
+	}
+	if s.extra {
+		n.Addr().Interface().(extra).extra(p, o.Addr().Interface())
+	}
+	switch {
+	case len(s.children) == 0:
+	case same && s.total >= 0 && n.Field(s.total).Uint() == o.Field(s.total).Uint() && s.paired(n, o):
+		for _, i := range s.children {
+			var (
+				a, b = n.Field(i), o.Field(i)
+				x    = order(a)
+			)
+			for j := 0; j < a.Len(); j++ {
+				k := j
+				if x != nil {
+					k = x[j]
+				}
+				if k >= b.Len() {
+					continue
+				}
+				diff(p, a.Index(k), b.Index(k), schemaOf(a.Index(k).Type()).same(a.Index(k), b.Index(k)))
+			}
+		}
+	default:
+		for _, i := range s.children {
+			children(p, n.Field(i), o.Field(i))
+		}
+	}
+	if s.key >= 0 {
+		p.rollbackPrefix()
+	}
+}
+
+// paired returns true if the children of both items have the same keys in the same
+// positions, so they can be compared by position. Equal totals do not guarantee this,
+// as different lists can still have the same total.
+func (s *schema) paired(n, o reflect.Value) bool {
+	for _, i := range s.children {
+		a, b := n.Field(i), o.Field(i)
+		if a.Len() != b.Len() {
+			return false
+		}
+		k := schemaOf(a.Type().Elem()).key
+		for x := 0; x < a.Len(); x++ {
+			if a.Index(x).Field(k).Uint() != b.Index(x).Field(k).Uint() {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// order returns the indexes of the keyed items in the slice in order of their keys, or
+// nil if the items are already in order.
+func order(v reflect.Value) []int {
+	k := schemaOf(v.Type().Elem()).key
+	for i := 1; i < v.Len(); i++ {
+		if v.Index(i-1).Field(k).Uint() <= v.Index(i).Field(k).Uint() {
+			continue
+		}
+		o := make([]int, v.Len())
+		for x := range o {
+			o[x] = x
+		}
+		sort.SliceStable(o, func(a, b int) bool {
+			return v.Index(o[a]).Field(k).Uint() < v.Index(o[b]).Field(k).Uint()
+		})
+		return o
+	}
+	return nil
+}
+
+// children compares the new and old children by their keys, in order of their keys. New
+// children are compared against an empty item and removed children are removed from the
+// board after the others.
+func children(p *planner, n, o reflect.Value) {
+	var (
+		s    = schemaOf(n.Type().Elem())
+		a, b = order(n), order(o)
+		e    reflect.Value
+		r    []uint64
+		x    int
+	)
+	at := func(v reflect.Value, l []int, i int) reflect.Value {
+		if l != nil {
+			return v.Index(l[i])
+		}
+		return v.Index(i)
+	}
+	for i := 0; i < n.Len(); i++ {
+		var (
+			v = at(n, a, i)
+			k = v.Field(s.key).Uint()
+		)
+		for ; x < o.Len() && at(o, b, x).Field(s.key).Uint() < k; x++ {
+			r = append(r, at(o, b, x).Field(s.key).Uint())
+		}
+		if x < o.Len() && at(o, b, x).Field(s.key).Uint() == k {
+			diff(p, v, at(o, b, x), s.same(v, at(o, b, x)))
+			x++
+			continue
+		}
+		if !e.IsValid() {
+			e = reflect.New(n.Type().Elem()).Elem()
+		}
+		diff(p, v, e, false)
+	}
+	for ; x < o.Len(); x++ {
+		r = append(r, at(o, b, x).Field(s.key).Uint())
+	}
+	for _, k := range r {
+		p.Remove(s.prefix + strconv.FormatUint(k, 10))
+	}
+}
+
+// value returns the field value as a string without boxing it, unless it's a Stringer.
+func (t *tag) value(v reflect.Value) string {
+	f := v.Field(t.index)
+	if t.str {
+		return f.Interface().(stringer).String()
+	}
+	switch f.Kind() {
+	case reflect.String:
+		return f.String()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(f.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(f.Uint(), 10)
+	}
+	return printStr(f.Interface())
+}
+func (t *tag) emit(p *planner, v reflect.Value, i string, same bool) {
+	switch t.kind {
+	case tagElement:
+		p.add(update{ID: i, Value: p.text(""), Class: t.arg}, !same)
+	case tagValue:
+		c := t.arg
+		if t.class >= 0 {
+			c = v.Field(t.class).Interface().(classer).class()
+		}
+		p.add(update{ID: i, Value: p.text(t.value(v)), Class: c}, !same)
+	case tagProperty:
+		x := t.value(v)
+		if len(t.format) > 0 {
+			x = strings.Replace(t.format, "%s", x, 1)
+		}
+		p.add(update{ID: i, Name: t.arg, Value: p.text(x)}, !same)
+	case tagClass:
+		x := &t.off
+		if v.Field(t.index).Bool() != t.invert {
+			x = &t.on
+		}
+		p.add(update{ID: i, Name: "class", Value: x}, !same)
+	}
+}
@@ -16,13 +16,13 @@
 
 package game
 
-import "strconv"
-
-var (
-	emptyTweet  tweet
-	emptyEvents events
+import (
+	"sort"
+	"strconv"
 )
 
+var emptyTweet tweet
+
 type event struct {
 	Data map[string]string `json:"data"`
 	ID   uint64            `json:"id"`
@@ -40,12 +40,21 @@ func (e event) Sum() uint64 {
 }
 func (e *events) Hash(h *hasher) uint64 {
 	if e.hash == 0 {
+		sort.Slice(e.Current, func(i, j int) bool { return e.Current[i].ID < e.Current[j].ID })
+		var k []string
 		for i := range e.Current {
 			h.Hash(e.Current[i].ID)
 			h.Hash(e.Current[i].Type)
-			for k, v := range e.Current[i].Data {
-				h.Hash(k)
+			// NOTE(dij): Map order is random, so the keys need to be sorted
+			//            to keep the same hash for the same event.
+			k = k[:0]
+			for v := range e.Current[i].Data {
+				k = append(k, v)
+			}
+			sort.Strings(k)
+			for _, v := range k {
 				h.Hash(v)
+				h.Hash(e.Current[i].Data[v])
 			}
 		}
 		e.hash = h.Segment()
@@ -87,6 +96,7 @@ func compareTweet(p *planner, n, o tweet) {
 }
 func (g *game) hashTweets(h *hasher) uint64 {
 	if g.tweets == 0 {
+		sort.Slice(g.Tweets, func(i, j int) bool { return g.Tweets[i].ID < g.Tweets[j].ID })
 		for i := range g.Tweets {
 			h.Hash(g.Tweets[i].ID)
 		}
@@ -110,16 +120,21 @@ func (g game) compareTweets(p *planner, o *game) {
 	for i := range g.Tweets {
 		c.Two(g.Tweets[i])
 	}
-	for k, v := range c {
-		switch {
+	x := c.Keys()
+	for _, k := range x {
+		switch v := c[k]; {
 		case !v.Second():
-			p.Remove("tweet-t" + strconv.FormatUint(k, 10))
 		case !v.First():
 			compareTweet(p, v.B.(tweet), emptyTweet)
 		default:
 			compareTweet(p, v.B.(tweet), v.A.(tweet))
 		}
 	}
+	for _, k := range x {
+		if !c[k].Second() {
+			p.Remove("tweet-t" + strconv.FormatUint(k, 10))
+		}
+	}
 }
 func (e *events) Compare(p *planner, o events) {
 	if o.hash == 0 {
@@ -138,9 +153,10 @@ func (e *events) Compare(p *planner, o events) {
 	for i := range e.Current {
 		c.Two(e.Current[i])
 	}
-	for k, v := range c {
+	x := c.Keys()
+	for _, k := range x {
+		v := c[k]
 		if !v.Second() {
-			p.RemoveEvent(k, v.A.(event).Type)
 			continue
 		}
 		if v.B.(event).Type > 0 {
@@ -152,6 +168,11 @@ func (e *events) Compare(p *planner, o events) {
 		}
 		p.Event(k, v.B.(event).Type, v.B.(event).Data)
 	}
+	for _, k := range x {
+		if v := c[k]; !v.Second() {
+			p.RemoveEvent(k, v.A.(event).Type)
+		}
+	}
 }
 func (e *events) setWindowEvent(p *planner, w event) {
 	if w.Type <= 0 || e.Window.ID == w.ID {
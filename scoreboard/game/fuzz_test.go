@@ -0,0 +1,105 @@
+// Copyright(C) 2020 - 2023 iDigitalFlame
+//
+// This program is free software: you can redistribute it and / or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, either version 3 of the License, or
+// any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.If not, see <https://www.gnu.org/licenses/>.
+//
+
+package game
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+// testCorpus returns the Scorebot payloads in testdata/scorebot, in order. These were
+// captured from consecutive ticks of the mock Scorebot server.
+func testCorpus(t testing.TB) [][]byte {
+	f, err := filepath.Glob(filepath.Join("testdata", "scorebot", "*.json"))
+	if err != nil || len(f) == 0 {
+		t.Fatalf("Could not find the Scorebot corpus: %v", err)
+	}
+	sort.Strings(f)
+	o := make([][]byte, 0, len(f))
+	for i := range f {
+		b, err := os.ReadFile(f[i])
+		if err != nil {
+			t.Fatalf("Could not read %q: %s", f[i], err)
+		}
+		o = append(o, b)
+	}
+	return o
+}
+func FuzzGameUnmarshal(f *testing.F) {
+	for _, b := range testCorpus(f) {
+		f.Add(b)
+	}
+	f.Add([]byte(testGame(1)))
+	f.Add([]byte(`{"teams":null,"events":null}`))
+	f.Fuzz(func(t *testing.T, b []byte) {
+		var g game
+		if err := json.Unmarshal(b, &g); err != nil {
+			return
+		}
+		// NOTE(dij): Any Game that decodes should be able to go on the board.
+		g.Delta("", nil)
+	})
+}
+func FuzzStateUnmarshal(f *testing.F) {
+	for _, v := range []string{`"green"`, `"yellow"`, `"red"`, `"G"`, `"ok"`, `"issue"`, `"fail"`, `"blue"`, `""`, `1`, `null`} {
+		f.Add([]byte(v))
+	}
+	f.Fuzz(func(t *testing.T, b []byte) {
+		var s state
+		if err := json.Unmarshal(b, &s); err != nil {
+			return
+		}
+		if s != red && s != yellow && s != green {
+			t.Fatalf("Unmarshal %q returned an unknown state %d", b, s)
+		}
+	})
+}
+func FuzzHelloUnmarshal(f *testing.F) {
+	for _, v := range []string{`{"game":1}`, `{"game":1,"seq":42,"epoch":3}`, `{"seq":1}`, `{"game":-1}`, `[]`, `{}`} {
+		f.Add([]byte(v))
+	}
+	f.Fuzz(func(t *testing.T, b []byte) {
+		var h hello
+		if err := json.Unmarshal(b, &h); err != nil {
+			return
+		}
+		var m map[string]uint64
+		if err := json.Unmarshal(b, &m); err != nil {
+			t.Fatalf("Unmarshal %q returned a hello from invalid JSON", b)
+		}
+		if _, ok := m["game"]; !ok || h.Game != m["game"] || h.Seq != m["seq"] || h.Epoch != m["epoch"] {
+			t.Fatalf("Unmarshal %q returned the wrong hello %+v", b, h)
+		}
+	})
+}
+func FuzzProtocolUnmarshal(f *testing.F) {
+	for _, v := range []string{`"tcp"`, `"udp"`, `"icmp"`, `"T"`, `"ping"`, `"sctp"`, `""`, `0`, `null`} {
+		f.Add([]byte(v))
+	}
+	f.Fuzz(func(t *testing.T, b []byte) {
+		var p protocol
+		if err := json.Unmarshal(b, &p); err != nil {
+			return
+		}
+		if p != tcp && p != udp && p != icmp {
+			t.Fatalf("Unmarshal %q returned an unknown protocol %d", b, p)
+		}
+	})
+}
@@ -18,8 +18,8 @@ package game
 
 import (
 	"encoding/json"
+	"reflect"
 	"sort"
-	"strconv"
 	"sync"
 	"time"
 )
@@ -51,26 +51,40 @@ var (
 type mode uint8
 type status uint8
 type meta struct {
-	End   time.Time `json:"end"`
-	Start time.Time `json:"start"`
-	Name  string    `json:"name"`
+	End   time.Time `json:"end" sb:"hash"`
+	Start time.Time `json:"start" sb:"hash"`
+	Name  string    `json:"name" sb:"value,status-name,game-name"`
 
-	ID   uint64 `json:"id"`
+	ID   uint64 `json:"id" sb:"hash"`
 	hash uint64
+	pos  uint64
 
-	Mode   mode   `json:"mode"`
-	Status status `json:"status"`
+	Mode   mode   `json:"mode" sb:"value,status-mode,game-mode"`
+	Status status `json:"status" sb:"value,status-status,game-status"`
 }
 type game struct {
-	Credit  string
-	Message string
-	Teams   []team
+	_       struct{} `sb:"element,status,status"`
+	Credit  string   `sb:"value,credit,game-credit"`
+	Message string   `sb:"value,message,game-message"`
+	Teams   []team   `sb:"children"`
 	Tweets  []tweet
 	Events  events
-	Meta    meta
+	Meta    meta `sb:"nested"`
+	notices []notice
+	ranking ranking
+	pos     uint64
 	hash    uint64
 	total   uint64
 	tweets  uint64
+	// create is the create list made by the last Delta of this Game, which holds the
+	// element IDs of every item at their positions.
+	create []update
+	// attackers is the result of 'attacks' for this Game, kept so the next Game can
+	// compare against it without building it again.
+	attackers []attack
+	// announce is the set of activity types created when comparing against the last
+	// Game state, which are stored in notices.
+	announce kinds
 }
 
 func (g game) Len() int {
@@ -128,18 +142,6 @@ func (s status) String() string {
 func (g game) Less(i, j int) bool {
 	return g.Teams[i].ID < g.Teams[j].ID
 }
-func (m *meta) Hash(h *hasher) uint64 {
-	if m.hash == 0 {
-		h.Hash(m.ID)
-		h.Hash(m.Mode)
-		h.Hash(m.Name)
-		h.Hash(m.Status)
-		h.Hash(m.End.Unix())
-		h.Hash(m.Start.Unix())
-		m.hash = h.Segment()
-	}
-	return m.hash
-}
 func (g *game) UnmarshalJSON(b []byte) error {
 	var m map[string]json.RawMessage
 	if err := json.Unmarshal(b, &m); err != nil {
@@ -179,84 +181,76 @@ func (g *game) UnmarshalJSON(b []byte) error {
 }
 func (g *game) Compare(p *planner, o *game) {
 	p.Prefix("game")
-	if o != nil && o.hash == g.hash && len(o.Teams) == len(g.Teams) {
-		p.Value("status", "", "status")
-		p.Value("credit", g.Credit, "game-credit")
-		p.Value("message", g.Message, "game-message")
-		g.Meta.Compare(p, o.Meta)
-		g.Events.Compare(p, o.Events)
-		g.compareTweets(p, o)
+	if o != nil && p.announce != 0 {
+		p.names = make(map[uint64]string, len(g.Teams))
 		for i := range g.Teams {
-			g.Teams[i].Compare(p, o.Teams[i])
-		}
-		p.rollbackPrefix()
-		return
-	}
-	p.DeltaValue("status", "", "status")
-	p.DeltaValue("credit", g.Credit, "game-credit")
-	p.DeltaValue("message", g.Message, "game-message")
-	c := make(compare)
-	if o != nil {
-		g.Meta.Compare(p, o.Meta)
-		g.Events.Compare(p, o.Events)
-		g.compareTweets(p, o)
-		for i := range o.Teams {
-			c.One(o.Teams[i])
+			p.names[g.Teams[i].ID] = g.Teams[i].Name
 		}
-	} else {
-		g.Meta.Compare(p, emptyMeta)
-		g.Events.Compare(p, emptyEvents)
-		g.compareTweets(p, nil)
-	}
-	for i := range g.Teams {
-		c.Two(g.Teams[i])
 	}
-	for k, v := range c {
-		switch {
-		case !v.Second():
-			p.Remove("team-t" + strconv.FormatUint(k, 10))
-		case !v.First():
-			v.B.(team).Compare(p, emptyTeam)
-		default:
-			v.B.(team).Compare(p, v.A.(team))
-		}
+	s := o != nil && o.hash == g.hash && len(o.Teams) == len(g.Teams)
+	if o == nil {
+		o = new(game)
 	}
+	diff(p, reflect.ValueOf(g).Elem(), reflect.ValueOf(o).Elem(), s)
 	p.rollbackPrefix()
 }
-func (m meta) Compare(p *planner, old meta) {
-	if old.ID != 0 && old.hash == m.hash {
-		p.Value("status-name", m.Name, "game-name")
-		p.Value("status-mode", m.Mode, "game-mode")
-		p.Value("status-status", m.Status, "game-status")
-		return
-	}
-	p.DeltaValue("status-name", m.Name, "game-name")
-	p.DeltaValue("status-mode", m.Mode, "game-mode")
-	p.DeltaValue("status-status", m.Status, "game-status")
+func (g *game) extra(p *planner, v interface{}) {
+	o := v.(*game)
+	g.Events.Compare(p, o.Events)
+	g.compareTweets(p, o)
 }
 func (g *game) Delta(s string, old *game) ([]update, []update) {
-	p := new(planner)
+	p := &planner{announce: g.announce}
 	sort.Sort(g)
 	if g.hash == 0 {
 		h := hashers.Get().(*hasher)
-		h.Hash(g.Message)
-		g.hash = h.Segment()
-		g.Meta.Hash(h)
 		for i := range g.Teams {
 			if g.Teams[i].Logo == "default.png" || len(g.Teams[i].Logo) == 0 {
 				g.Teams[i].Logo = "/image/team.png"
 			} else {
 				g.Teams[i].Logo = s + g.Teams[i].Logo
 			}
-			g.Teams[i].Hash(h)
 		}
-		g.total = h.Sum64()
+		sum(h, reflect.ValueOf(g).Elem())
 		h.Reset()
 		g.Events.Hash(h)
 		h.Reset()
 		g.hashTweets(h)
 		hashers.Put(h)
 	}
+	// NOTE(dij): Every update is added to the create list, so it's sized from the
+	//            last Game (plus some room) to prevent it from growing on each tick.
+	//            Full deltas contain the same updates as the create list.
+	if old != nil && len(old.create) > 0 {
+		p.Create, p.old = make([]update, 0, len(old.create)+len(old.create)/8), old.create
+	} else {
+		p.Create = make([]update, 0, g.estimate())
+		p.Delta = make([]update, 0, cap(p.Create))
+	}
+	g.rank(g.ranking, old)
 	g.Compare(p, old)
+	g.notices, g.create = p.notices, p.Create
 	return p.Create, p.Delta
 }
+
+// Offense returns the create and delta update lists for the offense view. These are kept
+// apart from the board lists, as only offense view clients have the offense container.
+func (g *game) Offense(old *game) ([]update, []update) {
+	p := new(planner)
+	p.Prefix("game")
+	g.compareOffense(p, old)
+	p.rollbackPrefix()
+	return p.Create, p.Delta
+}
+
+// estimate returns the rough number of updates needed to create the board for this Game.
+func (g *game) estimate() int {
+	n := 16 + len(g.Events.Current)*4 + len(g.Tweets)*8
+	for i := range g.Teams {
+		n += 24 + len(g.Teams[i].Beacons)*4
+		for x := range g.Teams[i].Hosts {
+			n += 8 + len(g.Teams[i].Hosts[x].Services)*5
+		}
+	}
+	return n
+}
@@ -18,8 +18,8 @@ package game
 
 import (
 	"errors"
-	"sync"
-	"unsafe"
+	"math"
+	"reflect"
 )
 
 const (
@@ -27,13 +27,6 @@ const (
 	fnvStart = 14695981039346656037
 )
 
-var bufs = sync.Pool{
-	New: func() interface{} {
-		b := make([]byte, 8)
-		return &b
-	},
-}
-
 type hasher struct {
 	h, s uint64
 }
@@ -47,16 +40,46 @@ func (h *hasher) Reset() {
 func (h hasher) Sum64() uint64 {
 	return h.h
 }
-func (h *hasher) Write(b []byte) {
+func (h *hasher) start() {
 	if h.h == 0 {
 		h.h = fnvStart
 	}
 	if h.s == 0 {
 		h.s = fnvStart
 	}
+}
+func (h *hasher) Bool(v bool) {
+	if v {
+		h.bytes(1, 1)
+	} else {
+		h.bytes(0, 1)
+	}
+}
+func (h *hasher) Write(b []byte) {
+	h.start()
 	h.s = updateFnv(h.s, b)
 	h.h = updateFnv(h.h, b)
 }
+func (h *hasher) String(v string) {
+	h.length(len(v))
+	h.start()
+	for i := 0; i < len(v); i++ {
+		h.s = (h.s * fnvPrime) ^ uint64(v[i])
+		h.h = (h.h * fnvPrime) ^ uint64(v[i])
+	}
+}
+func (h *hasher) Uint64(v uint64) {
+	h.bytes(v, 8)
+}
+
+// bytes writes the lowest n bytes of the value, in big endian order.
+func (h *hasher) bytes(v uint64, n int) {
+	var b [8]byte
+	for i := 0; i < n; i++ {
+		b[i] = byte(v >> uint(8*(n-i-1)))
+	}
+	h.Write(b[:n])
+}
 func (h *hasher) Segment() uint64 {
 	v := h.s
 	h.s = fnvStart
@@ -69,83 +92,83 @@ func updateFnv(h uint64, b []byte) uint64 {
 	}
 	return h
 }
+
+// fold adds the value to the tree hash. Unlike adding the hashes together, the result
+// depends on the order and position of each value, so moving a change between siblings
+// or between an item and its children changes the result.
+//
+// Each step is still 64-bit FNV, like the item hashes, so different values can fold into
+// the same total and payloads can be crafted to do so. Children with a matching total
+// are only compared by position if their keys also match (see 'paired'), so a collision
+// cannot pair different items together.
+func fold(h, v uint64) uint64 {
+	for i := 56; i >= 0; i -= 8 {
+		h *= fnvPrime
+		h ^= (v >> uint(i)) & 0xFF
+	}
+	return h
+}
+func (h *hasher) length(n int) {
+	h.bytes(uint64(n), 4)
+}
+
+// hashValue adds the reflect value to the hash without boxing it, unless it's a
+// Stringer (s is true) or an unsupported type.
+func (h *hasher) hashValue(v reflect.Value, s bool) {
+	if s {
+		h.String(v.Interface().(stringer).String())
+		return
+	}
+	switch v.Kind() {
+	case reflect.String:
+		h.String(v.String())
+	case reflect.Bool:
+		h.Bool(v.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		h.bytes(uint64(v.Int()), int(v.Type().Size()))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		h.bytes(v.Uint(), int(v.Type().Size()))
+	default:
+		h.Hash(v.Interface())
+	}
+}
 func (h *hasher) Hash(v interface{}) error {
-	b := *bufs.Get().(*[]byte)
-	_ = b[7]
 	switch i := v.(type) {
 	case bool:
-		if i {
-			b[0] = 1
-		} else {
-			b[0] = 0
-		}
-		h.Write(b[:1])
+		h.Bool(i)
 	case []byte:
+		h.length(len(i))
 		h.Write(i)
 	case string:
-		h.Write([]byte(i))
+		h.String(i)
 	case float32:
-		n := *(*uint32)(unsafe.Pointer(&i))
-		b[0], b[1] = byte(n>>24), byte(n>>16)
-		b[2], b[3] = byte(n>>8), byte(n)
-		h.Write(b[:4])
+		h.bytes(uint64(math.Float32bits(i)), 4)
 	case float64:
-		n := *(*uint64)(unsafe.Pointer(&i))
-		b[0], b[1] = byte(n>>56), byte(n>>48)
-		b[2], b[3] = byte(n>>40), byte(n>>32)
-		b[4], b[5] = byte(n>>24), byte(n>>16)
-		b[6], b[7] = byte(n>>8), byte(n)
-		h.Write(b)
+		h.bytes(math.Float64bits(i), 8)
 	case int8:
-		b[0] = uint8(i)
-		h.Write(b[:1])
+		h.bytes(uint64(i), 1)
 	case uint8:
-		b[0] = i
-		h.Write(b[:1])
+		h.bytes(uint64(i), 1)
 	case int16:
-		b[0], b[1] = byte(i>>8), byte(i)
-		h.Write(b[:2])
+		h.bytes(uint64(i), 2)
 	case uint16:
-		b[0], b[1] = byte(i>>8), byte(i)
-		h.Write(b[:2])
+		h.bytes(uint64(i), 2)
 	case int32:
-		b[0], b[1] = byte(i>>24), byte(i>>16)
-		b[2], b[3] = byte(i>>8), byte(i)
-		h.Write(b[:4])
+		h.bytes(uint64(i), 4)
 	case uint32:
-		b[0], b[1] = byte(i>>24), byte(i>>16)
-		b[2], b[3] = byte(i>>8), byte(i)
-		h.Write(b[:4])
+		h.bytes(uint64(i), 4)
 	case int64:
-		b[0], b[1] = byte(i>>56), byte(i>>48)
-		b[2], b[3] = byte(i>>40), byte(i>>32)
-		b[4], b[5] = byte(i>>24), byte(i>>16)
-		b[6], b[7] = byte(i>>8), byte(i)
-		h.Write(b)
+		h.bytes(uint64(i), 8)
 	case uint64:
-		b[0], b[1] = byte(i>>56), byte(i>>48)
-		b[2], b[3] = byte(i>>40), byte(i>>32)
-		b[4], b[5] = byte(i>>24), byte(i>>16)
-		b[6], b[7] = byte(i>>8), byte(i)
-		h.Write(b)
+		h.bytes(i, 8)
 	case int:
-		b[0], b[1] = byte(i>>56), byte(i>>48)
-		b[2], b[3] = byte(i>>40), byte(i>>32)
-		b[4], b[5] = byte(i>>24), byte(i>>16)
-		b[6], b[7] = byte(i>>8), byte(i)
-		h.Write(b)
+		h.bytes(uint64(i), 8)
 	case uint:
-		b[0], b[1] = byte(i>>56), byte(i>>48)
-		b[2], b[3] = byte(i>>40), byte(i>>32)
-		b[4], b[5] = byte(i>>24), byte(i>>16)
-		b[6], b[7] = byte(i>>8), byte(i)
-		h.Write(b)
+		h.bytes(uint64(i), 8)
 	case stringer:
-		h.Write([]byte(v.(stringer).String()))
+		h.String(i.String())
 	default:
-		bufs.Put(&b)
 		return errors.New("cannot hash the requested type")
 	}
-	bufs.Put(&b)
 	return nil
 }
@@ -0,0 +1,242 @@
+// Copyright(C) 2020 - 2023 iDigitalFlame
+//
+// This program is free software: you can redistribute it and / or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, either version 3 of the License, or
+// any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.If not, see <https://www.gnu.org/licenses/>.
+//
+
+package game
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func testBoard(teams, hosts, services int) *game {
+	g := &game{Message: "Hello", Meta: meta{ID: 1, Name: "Bench", Status: running}}
+	for i := 1; i <= teams; i++ {
+		t := team{ID: uint64(i), Name: "Team-" + strconv.Itoa(i), Logo: "/image/team.png", Color: "#0000FF"}
+		t.Score = score{Total: int64(i * 100), Health: 100}
+		for x := 1; x <= hosts; x++ {
+			h := host{ID: uint64(i*1000 + x), Name: "host-" + strconv.Itoa(x), Online: true}
+			for y := 1; y <= services; y++ {
+				h.Services = append(h.Services, service{ID: uint64(i*100000 + x*100 + y), Port: uint16(y * 10), Protocol: tcp})
+			}
+			t.Hosts = append(t.Hosts, h)
+		}
+		t.Beacons = append(t.Beacons, beacon{ID: uint64(i), Team: uint64(teams - i + 1), Color: "#FF0000"})
+		g.Teams = append(g.Teams, t)
+	}
+	return g
+}
+func hasUpdate(u []update, i, n, v string) bool {
+	for _, x := range u {
+		if x.ID == i && x.Name == n && x.Value != nil && *x.Value == v {
+			return true
+		}
+	}
+	return false
+}
+
+// additive returns the old style total of the Game, which was the sum of the hashes of
+// every item in it.
+func additive(g *game) uint64 {
+	r := g.hash + g.Meta.hash
+	for _, t := range g.Teams {
+		r += t.hash + t.Score.hash + t.Flags.hash + t.Tickets.hash
+		for _, b := range t.Beacons {
+			r += b.hash
+		}
+		for _, h := range t.Hosts {
+			r += h.hash
+			for _, s := range h.Services {
+				r += s.hash
+			}
+		}
+	}
+	return r
+}
+func TestHashCollisions(t *testing.T) {
+	// NOTE(dij): Services moving between the Hosts of a Team do not change any
+	//            item hash, so adding the hashes together gave the same Team total
+	//            and the moved services were never compared.
+	for _, v := range []struct {
+		s [2][][]service
+		i string
+	}{
+		{ // Services swapped between Hosts.
+			s: [2][][]service{
+				{{{ID: 1, Port: 80, State: green}}, {{ID: 2, Port: 25, State: red}}},
+				{{{ID: 2, Port: 25, State: red}}, {{ID: 1, Port: 80, State: green}}},
+			},
+			i: "game-team-t1-host-h1-s2-port",
+		},
+		{ // A service moved to another Host.
+			s: [2][][]service{
+				{{{ID: 1, Port: 80, State: green}, {ID: 2, Port: 25, State: red}}, {}},
+				{{{ID: 1, Port: 80, State: green}}, {{ID: 2, Port: 25, State: red}}},
+			},
+			i: "game-team-t1-host-h2-s2-port",
+		},
+	} {
+		var o, n *game
+		for i, x := range []**game{&o, &n} {
+			*x = &game{Meta: meta{ID: 1}, Teams: []team{{ID: 1, Name: "Blue", Hosts: []host{
+				{ID: 1, Name: "www", Online: true, Services: v.s[i][0]},
+				{ID: 2, Name: "mail", Online: true, Services: v.s[i][1]},
+			}}}}
+		}
+		o.Delta("", nil)
+		_, d := n.Delta("", o)
+		if additive(o) != additive(n) {
+			t.Fatalf("additive totals should match for %+v", v.s)
+		}
+		if o.Teams[0].total == n.Teams[0].total {
+			t.Fatalf("team totals should not match for %+v", v.s)
+		}
+		if !hasUpdate(d, v.i, "", "25") {
+			t.Fatalf("missing moved service update in %+v", d)
+		}
+	}
+	// The same bytes split differently between fields.
+	h := new(hasher)
+	x := team{ID: 1, Name: "Red", Logo: "/image/team.png"}
+	y := team{ID: 1, Name: "Red/image", Logo: "/team.png"}
+	sum(h, reflect.ValueOf(&x).Elem())
+	sum(h, reflect.ValueOf(&y).Elem())
+	if x.hash == y.hash {
+		t.Fatalf("split string fields should not have the same hash")
+	}
+}
+func TestHashTotalCollision(t *testing.T) {
+	// NOTE(dij): The Team totals are forced to match, like a collision would, while
+	//            the Team has a different Host.
+	o, n := testBoard(1, 1, 1), testBoard(1, 1, 1)
+	n.Teams[0].Hosts[0].ID = 2000
+	o.Delta("", nil)
+	sum(new(hasher), reflect.ValueOf(n).Elem())
+	n.Teams[0].total = o.Teams[0].total
+	_, d := n.Delta("", o)
+	var r bool
+	for _, u := range d {
+		r = r || (u.Remove && u.ID == "game-team-t1-host-h1001")
+	}
+	if !r {
+		t.Fatalf("Delta did not remove the old Host in %+v", d)
+	}
+	if !hasUpdate(d, "game-team-t1-host-h2000-name", "", "host-1") {
+		t.Fatalf("Delta did not add the new Host in %+v", d)
+	}
+}
+func BenchmarkHash(b *testing.B) {
+	v := make([]*game, b.N)
+	for i := range v {
+		v[i] = testBoard(10, 10, 5)
+	}
+	h := new(hasher)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		sum(h, reflect.ValueOf(v[i]).Elem())
+	}
+}
+
+// benchmarkTotal times the total of an already hashed board, so only the way the item
+// hashes are combined is measured.
+func benchmarkTotal(b *testing.B, f func(*hasher, *game) uint64) {
+	// NOTE(dij): Numbers from "go test -bench Total -benchmem" on a 60 Team board. The
+	//            tree total walks the model with reflection and folds every item, but
+	//            is still a small part of a Delta (see 'benchmarkDelta').
+	//
+	//            BenchmarkTotalAdditive      8.5us   0 B/op   0 allocs/op
+	//            BenchmarkTotalTree          320us   0 B/op   0 allocs/op
+	var (
+		g = testBoard(60, 15, 10)
+		h = new(hasher)
+		t uint64
+	)
+	sum(h, reflect.ValueOf(g).Elem())
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		t += f(h, g)
+	}
+	if t == 1 {
+		b.Log(t)
+	}
+}
+func BenchmarkTotalAdditive(b *testing.B) {
+	benchmarkTotal(b, func(_ *hasher, g *game) uint64 { return additive(g) })
+}
+func BenchmarkTotalTree(b *testing.B) {
+	benchmarkTotal(b, func(h *hasher, g *game) uint64 { return sum(h, reflect.ValueOf(g).Elem()) })
+}
+func benchmarkDelta(b *testing.B, change func(*game)) {
+	// NOTE(dij): Numbers from "go test -bench 'Delta|Hash' -benchmem", before and after
+	//            removing the interface boxing in the hasher and planner, sizing the
+	//            create list from the last Game and taking the element IDs of each
+	//            item from the last create list. Timings change between machines, so
+	//            only the memory numbers are kept.
+	//
+	//            BenchmarkHash             83888 B/op   5938 allocs/op
+	//                                       1114 B/op   1006 allocs/op
+	//            BenchmarkDeltaFull        47.8 MB/op  309316 allocs/op
+	//                                      10.2 MB/op   89133 allocs/op
+	//            BenchmarkDeltaTick        27.4 MB/op  318738 allocs/op
+	//                                       5.0 MB/op   48604 allocs/op
+	//            BenchmarkDeltaUnchanged   27.3 MB/op  318499 allocs/op
+	//                                       4.9 MB/op   48536 allocs/op
+	var (
+		o = make([]*game, b.N)
+		n = make([]*game, b.N)
+	)
+	for i := range o {
+		o[i], n[i] = testBoard(60, 15, 10), testBoard(60, 15, 10)
+		if o[i].Delta("/assets/", nil); change != nil {
+			change(n[i])
+		}
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		n[i].Delta("/assets/", o[i])
+		// NOTE(dij): Drop the Games once compared, so they are not all kept for the GC
+		//            to scan, as only the last Game is kept by a subscription.
+		o[i], n[i] = nil, nil
+	}
+}
+func BenchmarkDeltaFull(b *testing.B) {
+	v := make([]*game, b.N)
+	for i := range v {
+		v[i] = testBoard(60, 15, 10)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		v[i].Delta("/assets/", nil)
+		v[i] = nil
+	}
+}
+func BenchmarkDeltaTick(b *testing.B) {
+	benchmarkDelta(b, func(g *game) {
+		for i := range g.Teams {
+			if i%4 == 0 {
+				g.Teams[i].Score.Total += 10
+				g.Teams[i].Hosts[i%15].Services[i%10].State = red
+			}
+		}
+	})
+}
+func BenchmarkDeltaUnchanged(b *testing.B) {
+	benchmarkDelta(b, nil)
+}
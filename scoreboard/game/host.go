@@ -18,7 +18,6 @@ package game
 
 import (
 	"encoding/json"
-	"strconv"
 	"strings"
 )
 
@@ -32,37 +31,30 @@ const (
 	icmp protocol = 0x2
 )
 
-var (
-	emptyHost    host
-	emptyService service
-)
-
 type state uint8
 type host struct {
-	Name     string    `json:"name"`
-	Services []service `json:"services"`
-	ID       uint64    `json:"id"`
+	Name     string    `json:"name" sb:"value,name,host-name"`
+	Services []service `json:"services" sb:"children"`
+	ID       uint64    `json:"id" sb:"key,host-h,host"`
+	stat     uptime
+	pos      uint64
 	hash     uint64
 	total    uint64
-	Online   bool `json:"online"`
+	Online   bool `json:"online" sb:"class,,!offline"`
 }
 type protocol uint8
 type service struct {
-	ID       uint64   `json:"id"`
-	Port     uint16   `json:"port"`
-	State    state    `json:"status"`
-	Bonus    bool     `json:"bool"`
-	Protocol protocol `json:"protocol"`
+	ID       uint64   `json:"id" sb:"key,s,service"`
+	Port     uint16   `json:"port" sb:"value,port,@State"`
+	State    state    `json:"status" sb:"property,,background-color"`
+	Bonus    bool     `json:"bool" sb:"class,,bonus"`
+	Protocol protocol `json:"protocol" sb:"value,protocol,service-protocol"`
 
+	stat uptime
+	pos  uint64
 	hash uint64
 }
 
-func (h host) Sum() uint64 {
-	return h.ID
-}
-func (s service) Sum() uint64 {
-	return s.ID
-}
 func (s state) class() string {
 	switch s {
 	case red:
@@ -96,77 +88,19 @@ func (p protocol) String() string {
 	}
 	return "Unknown"
 }
-func (h *host) Hash(i *hasher) uint64 {
-	if h.hash == 0 {
-		i.Hash(h.ID)
-		i.Hash(h.Name)
-		i.Hash(h.Online)
-		h.hash = i.Segment()
+func (h *host) extra(p *planner, v interface{}) {
+	o := v.(*host)
+	compareUptime(p, h.stat, o.stat)
+	if p.host = h.Name; o.ID != 0 && o.total != h.total {
+		h.compareOnline(p, *o)
 	}
-	h.total = h.hash
-	for s := range h.Services {
-		h.total += h.Services[s].Hash(i)
-	}
-	return h.hash
 }
-func (s *service) Hash(h *hasher) uint64 {
-	if s.hash == 0 {
-		h.Hash(s.ID)
-		h.Hash(s.Port)
-		h.Hash(s.State)
-		h.Hash(s.Bonus)
-		h.Hash(s.Protocol)
-		s.hash = h.Segment()
+func (s *service) extra(p *planner, v interface{}) {
+	o := v.(*service)
+	compareUptime(p, s.stat, o.stat)
+	if o.ID != 0 && o.hash != s.hash {
+		s.compareState(p, *o)
 	}
-	return s.hash
-}
-func (h host) Compare(p *planner, o host) {
-	if o.ID == 0 {
-		p.DeltaValue("host-h"+strconv.FormatUint(h.ID, 10), "", "host")
-	} else {
-		p.Value("host-h"+strconv.FormatUint(h.ID, 10), "", "host")
-	}
-	p.Prefix(p.prefix + "-host-h" + strconv.FormatUint(h.ID, 10))
-	if o.hash == h.hash {
-		p.Value("name", h.Name, "host-name")
-		if h.Online {
-			p.Property("", "-offline", "class")
-		} else {
-			p.Property("", "+offline", "class")
-		}
-		if o.total == h.total {
-			for i := range h.Services {
-				h.Services[i].Compare(p, o.Services[i])
-			}
-		}
-	} else {
-		p.DeltaValue("name", h.Name, "host-name")
-		if h.Online {
-			p.DeltaProperty("", "-offline", "class")
-		} else {
-			p.DeltaProperty("", "+offline", "class")
-		}
-	}
-	if o.ID == 0 || o.total != h.total {
-		c := make(compare)
-		for i := range o.Services {
-			c.One(o.Services[i])
-		}
-		for i := range h.Services {
-			c.Two(h.Services[i])
-		}
-		for k, v := range c {
-			switch {
-			case !v.Second():
-				p.Remove("s" + strconv.FormatUint(k, 10))
-			case !v.First():
-				v.B.(service).Compare(p, emptyService)
-			default:
-				v.B.(service).Compare(p, v.A.(service))
-			}
-		}
-	}
-	p.rollbackPrefix()
 }
 func (s *state) UnmarshalJSON(b []byte) error {
 	var v string
@@ -185,35 +119,6 @@ func (s *state) UnmarshalJSON(b []byte) error {
 	}
 	return nil
 }
-func (s service) Compare(p *planner, o service) {
-	if o.ID == 0 {
-		p.DeltaValue("s"+strconv.FormatUint(s.ID, 10), "", "service")
-	} else {
-		p.Value("s"+strconv.FormatUint(s.ID, 10), "", "service")
-	}
-	p.Prefix(p.prefix + "-s" + strconv.FormatUint(s.ID, 10))
-	if o.hash == s.hash {
-		p.Value("port", s.Port, s.State.class())
-		p.Value("protocol", s.Protocol.String(), "service-protocol")
-		if s.Bonus {
-			p.Property("", "+bonus", "class")
-		} else {
-			p.Property("", "-bonus", "class")
-		}
-		p.Property("", s.State.String(), "background-color")
-		p.rollbackPrefix()
-		return
-	}
-	p.DeltaValue("port", s.Port, s.State.class())
-	p.DeltaValue("protocol", s.Protocol.String(), "service-protocol")
-	if s.Bonus {
-		p.DeltaProperty("", "+bonus", "class")
-	} else {
-		p.DeltaProperty("", "-bonus", "class")
-	}
-	p.DeltaProperty("", s.State.String(), "background-color")
-	p.rollbackPrefix()
-}
 func (p *protocol) UnmarshalJSON(b []byte) error {
 	var v string
 	if err := json.Unmarshal(b, &v); err != nil {
@@ -17,6 +17,7 @@
 package game
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
@@ -27,6 +28,7 @@ import (
 	"path"
 	"strconv"
 	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -36,9 +38,18 @@ import (
 	"github.com/gorilla/websocket"
 )
 
-var errMissingGame = errors.New("game ID is missing from JSON data")
+var (
+	errClosed      = errors.New("manager is closed")
+	errEmptyGame   = errors.New("game is empty")
+	errMissingGame = errors.New("game ID is missing from JSON data")
+)
 
-type hello uint64
+type hello struct {
+	Game    uint64
+	Seq     uint64
+	Epoch   uint64
+	Offense bool
+}
 type tweet struct {
 	User      string
 	Text      string
@@ -48,57 +59,80 @@ type tweet struct {
 	ID        uint64
 	expire    int64
 }
-type stream struct {
-	*websocket.Conn
-	ok bool
-}
 type tweets struct {
 	new     chan *twitter.Tweet
 	current []tweet
 	timeout time.Duration
+	lock    sync.RWMutex
 }
 
 // Manager is a struct that contains for a map of subs and controls the connections between Scorebot
 // and the Scoreboard clients.
+//
+// All the shared state (subs, active and games) is guarded by the Manager lock. The Game list and
+// name mappings are replaced as a whole on every update and are never modified in place, so any
+// references handed out by 'Games' or 'Game' are immutable snapshots.
 type Manager struct {
-	log     logx.Log
-	active  map[string]uint64
-	tick    *time.Ticker
-	subs    map[uint64]*subscription
-	client  *http.Client
-	twitter *tweets
-	url     url.URL
-	assets  string
-	Games   []meta
-	timeout time.Duration
-	running uint32
-}
-type subscription struct {
-	new     chan *websocket.Conn
-	cache   []update
-	clients []*stream
-	last    game
-	ID      uint64
-	stale   uint32
+	log       logx.Log
+	active    map[string]uint64
+	tick      *time.Ticker
+	subs      map[uint64]*subscription
+	client    *http.Client
+	twitter   *tweets
+	base      url.URL
+	cache     cache
+	breaker   breaker
+	auth      Auth
+	recorder  *recorder
+	replay    *replay
+	state     string
+	ranking   ranking
+	activity  activity
+	rules     []rule
+	sampling  time.Duration
+	retention time.Duration
+	assets    string
+	games     []meta
+	gsum      uint64
+	timeout   time.Duration
+	interval  time.Duration
+	workers   int
+	lock      sync.RWMutex
+	running   uint32
+	closed    bool
+	callouts  bool
 }
 
 func (m *Manager) close() {
+	m.lock.Lock()
+	m.closed = true
 	for n, s := range m.subs {
-		for i := range s.clients {
-			s.clients[i].Close()
-			s.clients[i] = nil
-		}
-		close(s.new)
+		s.lock.Lock()
+		s.shutdown()
+		s.lock.Unlock()
 		delete(m.subs, n)
 	}
+	m.lock.Unlock()
 	if m.twitter != nil {
+		m.twitter.lock.Lock()
 		m.twitter.current = nil
+		m.twitter.lock.Unlock()
 	}
 	m.tick.Stop()
+	m.recorder.close()
 }
 func (t tweet) Sum() uint64 {
 	return t.ID
 }
+func (t *tweets) get() []tweet {
+	if t == nil {
+		return nil
+	}
+	t.lock.RLock()
+	v := t.current
+	t.lock.RUnlock()
+	return v
+}
 func cleanSlugString(s string) string {
 	var b strings.Builder
 	b.Grow(len(s))
@@ -121,11 +155,33 @@ func cleanSlugString(s string) string {
 	return v
 }
 
+// Games returns the last list of Games read from Scorebot. The returned slice is a snapshot
+// and must not be modified.
+func (m *Manager) Games() []meta {
+	m.lock.RLock()
+	g := m.games
+	m.lock.RUnlock()
+	return g
+}
+func (m *Manager) meta(g *game) {
+	for _, v := range m.Games() {
+		if v.ID == g.Meta.ID {
+			g.Meta.End = v.End
+			g.Meta.Start = v.Start
+			g.Meta.Status = v.Status
+			break
+		}
+	}
+}
+
 // Game will attempt to resolve the game name provided to an active game ID. This function will replace
 // any spaces and invalid characters and matches the Game name without case sensitivity. THis function returns
 // zero if no Game was found
 func (m *Manager) Game(s string) uint64 {
-	return m.active[strings.ToLower(cleanSlugString(s))]
+	m.lock.RLock()
+	v := m.active[strings.ToLower(cleanSlugString(s))]
+	m.lock.RUnlock()
+	return v
 }
 
 // New attempts to add the supplied web client to the Subscription swarm.
@@ -142,45 +198,106 @@ func (m *Manager) New(n *websocket.Conn) {
 		n.Close()
 		return
 	}
-	m.log.Debug(`Received Hello with requested Game ID %d from "%s".`, h, n.RemoteAddr().String())
-	s, ok := m.subs[uint64(h)]
-	if !ok || s == nil {
-		m.log.Debug(`Checking Game ID %d, requested by "%s"..`, h, n.RemoteAddr().String())
-		var g game
-		if err := m.getJSON(context.Background(), "api/scoreboard/"+strconv.FormatUint(uint64(h), 10)+"/", &g); err != nil {
-			m.log.Error("Error retrieving data for Game ID %d: %s!", h, err.Error())
+	m.log.Debug(`Received Hello with requested Game ID %d from "%s".`, h.Game, n.RemoteAddr().String())
+	for {
+		s, err := m.subscribe(h.Game)
+		if err != nil {
+			m.log.Error("Error retrieving data for Game ID %d: %s!", h.Game, err.Error())
 			n.Close()
 			return
 		}
-		if len(g.Meta.Name) == 0 && len(g.Teams) == 0 {
-			m.log.Error("Game ID %d is empty, ignoring!", h)
-			n.Close()
+		if s.join(m, n, h) {
 			return
 		}
-		g.Meta.ID = uint64(h)
-		for i := range m.Games {
-			if m.Games[i].ID == g.Meta.ID {
-				g.Meta.End = m.Games[i].End
-				g.Meta.Start = m.Games[i].Start
-				g.Meta.Status = m.Games[i].Status
-				break
-			}
-		}
-		s = &subscription{
-			ID:      g.Meta.ID,
-			new:     make(chan *websocket.Conn, 128),
-			last:    g,
-			clients: make([]*stream, 0, 1),
-		}
-		if m.twitter != nil {
-			s.last.Tweets = m.twitter.current
-		}
-		s.cache, _ = s.last.Delta(m.assets, nil)
-		m.subs[g.Meta.ID] = s
+		// The subscription was removed before we could join, try again.
+	}
+}
+
+// subscribe returns the subscription for the supplied Game ID, creating it (and pulling the
+// initial Game data) if it does not exist.
+func (m *Manager) subscribe(i uint64) (*subscription, error) {
+	m.lock.RLock()
+	s, c := m.subs[i], m.closed
+	m.lock.RUnlock()
+	if c {
+		return nil, errClosed
+	}
+	if s != nil {
+		return s, nil
+	}
+	m.log.Debug("Checking Game ID %d..", i)
+	var (
+		g    game
+		b    []byte
+		h    uint64
+		err  error
+		t    = time.Now()
+		v, r = m.restore(i)
+	)
+	// NOTE(dij): Saved Game data is served right away (marked as stale) and refreshed
+	//            from Scorebot in the background, so clients don't wait on a request
+	//            that may time out if Scorebot is down.
+	if r {
+		m.log.Info("Using saved state for Game ID %d until it is refreshed from Scorebot.", i)
+		b, h, t = v.body, updateFnv(fnvStart, v.body), v.time
+	} else if b, h, err = m.get(context.Background(), "api/scoreboard/"+strconv.FormatUint(i, 10)); err != nil {
+		return nil, err
 	}
-	atomic.StoreUint32(&s.stale, 0)
-	n.WriteJSON(s.cache)
-	s.new <- n
+	if err = json.Unmarshal(b, &g); err != nil {
+		return nil, errors.New("unable to unmarshal JSON for Game " + strconv.FormatUint(i, 10) + ": " + err.Error())
+	}
+	if len(g.Meta.Name) == 0 && len(g.Teams) == 0 {
+		return nil, errEmptyGame
+	}
+	if !r {
+		m.persist(stateGame(i), b)
+	}
+	g.Meta.ID, g.Tweets, g.ranking = i, m.twitter.get(), m.ranking
+	m.meta(&g)
+	g.announce = m.activity.get(g.Meta)
+	n := &subscription{ID: i, last: g, epoch: uint64(time.Now().UnixMilli()), fetched: t, sum: h, restored: r}
+	n.rules.check(m, &n.last, t)
+	n.uptime.observe(&n.last, t, m.callouts)
+	n.beacons.observe(&n.last, t)
+	n.cache, _ = n.last.Delta(m.assets, nil)
+	n.offense, _ = n.last.Offense(nil)
+	n.sample(m, t)
+	m.lock.Lock()
+	if m.closed {
+		m.lock.Unlock()
+		return nil, errClosed
+	}
+	if s = m.subs[i]; s == nil {
+		m.subs[i], s = n, n
+	}
+	m.lock.Unlock()
+	if r && s == n {
+		go func() {
+			c, f := context.WithTimeout(context.Background(), m.timeout)
+			n.update(c, m)
+			f()
+		}()
+	}
+	return s, nil
+}
+
+// expire checks if the subscription has had no clients for a whole tick and removes it if
+// so. This returns true if the subscription was removed.
+func (m *Manager) expire(s *subscription) bool {
+	m.lock.Lock()
+	s.lock.Lock()
+	s.prune(m)
+	r := len(s.clients) == 0 && s.stale
+	if r {
+		s.shutdown()
+		delete(m.subs, s.ID)
+		m.cache.remove(m.url("api/scoreboard/" + strconv.FormatUint(s.ID, 10)))
+	} else {
+		s.stale = len(s.clients) == 0
+	}
+	s.lock.Unlock()
+	m.lock.Unlock()
+	return r
 }
 
 // Start will start the Manager content thread. This function takes a context that will be used
@@ -200,57 +317,139 @@ func (m *Manager) Start(x context.Context) {
 }
 func (m *Manager) update(x context.Context) {
 	m.log.Trace("Starting update..")
-	if err := m.getJSON(x, "api/games/", &m.Games); err != nil {
+	var (
+		g    []meta
+		h, n uint64
+		ok   bool
+		err  error
+	)
+	m.lock.RLock()
+	n = m.gsum
+	m.lock.RUnlock()
+	if h, ok, err = m.getJSON(x, "api/games/", n, &g); err != nil {
+		if err == errOffline {
+			m.log.Debug("Skipping update tick, Scorebot is offline.")
+			return
+		}
 		m.log.Error("Error occurred during update tick: %s", err.Error())
 		return
 	}
-	for i := range m.Games {
-		n := cleanSlugString(m.Games[i].Name)
-		if !m.Games[i].Active() {
-			delete(m.active, n)
-			continue
+	if !ok {
+		m.log.Trace("Games list is unchanged, skipping..")
+		m.lock.RLock()
+		l := make([]*subscription, 0, len(m.subs))
+		for _, s := range m.subs {
+			l = append(l, s)
 		}
-		if _, ok := m.active[n]; !ok {
-			m.active[n] = m.Games[i].ID
-			m.log.Debug(`Added Game name mapping "%s" to ID %d.`, n, m.Games[i].ID)
+		g = m.games
+		m.lock.RUnlock()
+		m.tail(x, l, len(g))
+		return
+	}
+	a := make(map[string]uint64, len(g))
+	for i := range g {
+		if !g[i].Active() {
+			continue
 		}
+		a[strings.ToLower(cleanSlugString(g[i].Name))] = g[i].ID
 	}
-	select {
-	case <-x.Done():
-		return
-	default:
-		break
+	m.lock.Lock()
+	for k, v := range a {
+		if _, ok := m.active[k]; !ok {
+			m.log.Debug(`Added Game name mapping "%s" to ID %d.`, k, v)
+		}
 	}
-	var r []uint64
+	m.games, m.active, m.gsum = g, a, h
+	l := make([]*subscription, 0, len(m.subs))
 	for _, s := range m.subs {
-		if len(s.clients) == 0 {
-			if atomic.LoadUint32(&s.stale) == 1 {
-				r = append(r, s.ID)
-				continue
+		l = append(l, s)
+	}
+	m.lock.Unlock()
+	m.persistGames(g)
+	m.tail(x, l, len(g))
+}
+func (m *Manager) tail(x context.Context, l []*subscription, n int) {
+	m.poll(x, l)
+	if m.twitter != nil {
+		m.twitter.update(x, m)
+	}
+	c := m.cache.stats()
+	m.log.Debug(
+		"Read %d Games from scorebot, update finished (cache: %d requests, %d not modified, %d unchanged, %.1f%% hit rate).",
+		n, c.Requests, c.NotModified, c.Unchanged, c.HitRate(),
+	)
+}
+
+// poll updates the supplied subscriptions using at most 'workers' goroutines and waits
+// for all of them. The context is only used to stop polling, each Game request gets its
+// own timeout in 'get', so a slow or failing Game will not cancel or delay the others
+// beyond holding a single worker.
+func (m *Manager) poll(x context.Context, l []*subscription) {
+	var (
+		w sync.WaitGroup
+		q = make(chan *subscription)
+	)
+	for i := 0; i < m.workers && i < len(l); i++ {
+		w.Add(1)
+		go func() {
+			defer w.Done()
+			for s := range q {
+				s.update(x, m)
 			}
-			atomic.StoreUint32(&s.stale, 1)
+		}()
+	}
+	for _, s := range l {
+		if m.expire(s) {
+			m.log.Debug("Removed unused subscription for Game %d.", s.ID)
+			continue
 		}
 		select {
+		case q <- s:
 		case <-x.Done():
-			return
-		default:
 		}
+	}
+	close(q)
+	w.Wait()
+}
+
+// Ingest accepts Game data pushed from Scorebot for the supplied Game ID and applies it to the
+// subscription immediately instead of waiting for the next tick. The data is the same JSON
+// document returned by the Scorebot scoreboard API. If the data is empty, this is treated as a
+// change notification and the Game is polled from Scorebot instead. Games without any
+// subscribers are ignored, as they will be loaded fresh when a client connects.
+func (m *Manager) Ingest(x context.Context, i uint64, b []byte) error {
+	m.lock.RLock()
+	s := m.subs[i]
+	m.lock.RUnlock()
+	if s == nil {
+		return nil
+	}
+	if len(bytes.TrimSpace(b)) == 0 {
+		m.log.Debug("Received change notification for Game %d, polling..", i)
 		s.update(x, m)
+		return nil
 	}
-	for i := range r {
-		select {
-		case <-x.Done():
-			return
-		default:
-		}
-		m.log.Debug("Removing unused subscription for Game %d.", r[i])
-		close(m.subs[r[i]].new)
-		delete(m.subs, r[i])
+	var (
+		g game
+		t = time.Now()
+	)
+	if err := json.Unmarshal(b, &g); err != nil {
+		return errors.New(`unable to unmarshal pushed JSON for Game ` + strconv.FormatUint(i, 10) + `: ` + err.Error())
 	}
-	if m.twitter != nil {
-		m.twitter.update(x, m)
+	m.log.Debug("Received pushed data for Game %d, applying..", i)
+	m.record("api/scoreboard/"+strconv.FormatUint(i, 10), b)
+	m.persist(stateGame(i), b)
+	s.apply(m, g, t, 0)
+	return nil
+}
+
+// SetWorkers sets the max number of subscribed Games that will be polled from Scorebot
+// at the same time. Values less than one are ignored. This function must be called
+// before 'Start'.
+func (m *Manager) SetWorkers(n int) {
+	if n > 0 {
+		m.workers = n
 	}
-	m.log.Debug("Read %d Games from scorebot, update finished.", len(m.Games))
 }
 func (h *hello) UnmarshalJSON(b []byte) error {
 	var m map[string]uint64
@@ -261,33 +460,31 @@ func (h *hello) UnmarshalJSON(b []byte) error {
 	if !ok {
 		return errMissingGame
 	}
-	*h = hello(v)
+	h.Game, h.Seq, h.Epoch, h.Offense = v, m["seq"], m["epoch"], m["offense"] == 1
 	return nil
 }
+
+// startUpdate runs a single update tick and waits for it to finish. The tick has no
+// deadline of its own, as every Scorebot request has its own timeout.
 func (m *Manager) startUpdate(x context.Context) {
 	atomic.StoreUint32(&m.running, 1)
-	c, f := context.WithTimeout(x, m.timeout)
-	go func(y context.Context, w context.CancelFunc, q *Manager) {
-		defer func() {
-			if err := recover(); err != nil {
-				q.log.Error("Panic occurred during manager tick: %s!", err)
-				w()
-			}
-		}()
-		q.update(y)
-		w()
-	}(c, f, m)
-	<-c.Done()
-	if c.Err() == context.DeadlineExceeded {
-		m.log.Warning("Collection update function ran over timeout of %s!", m.timeout.String())
-	}
-	f()
-	atomic.StoreUint32(&m.running, 0)
+	t := time.Now()
+	defer func() {
+		if err := recover(); err != nil {
+			m.log.Error("Panic occurred during manager tick: %s!", err)
+		}
+		if d := time.Since(t); d > m.interval {
+			m.log.Warning("Collection update function ran over the tick interval of %s (took %s)!", m.interval.String(), d.String())
+		}
+		atomic.StoreUint32(&m.running, 0)
+	}()
+	m.update(x)
 }
 func (t *tweets) update(x context.Context, m *Manager) {
 	var (
 		n = time.Now().Unix()
-		c = make([]tweet, 0, len(t.current))
+		o = t.get()
+		c = make([]tweet, 0, len(o))
 	)
 	for len(t.new) > 0 {
 		select {
@@ -322,133 +519,163 @@ func (t *tweets) update(x context.Context, m *Manager) {
 				r.Images = append(r.Images, x.Entities.Media[i].MediaURLHttps)
 			}
 		}
+		m.recordTweet(r)
 		c = append(c, r)
 	}
-	for i := range t.current {
+	if m.replay != nil {
+		p, w := m.replay.pending()
+		if w {
+			// NOTE(dij): The replay was moved backwards, so drop the shown Tweets as
+			//            they are from the "future".
+			o = nil
+		}
+		for i := range p {
+			p[i].expire = n + int64(t.timeout.Seconds())
+			c = append(c, p[i])
+		}
+	}
+	for i := range o {
 		select {
 		case <-x.Done():
 			return
 		default:
 		}
-		if t.current[i].expire > n {
-			c = append(c, t.current[i])
+		if o[i].expire > n {
+			c = append(c, o[i])
+			continue
 		}
-		m.log.Debug("Removed Tweet ID \"%X\" due to timeout!", t.current[i].ID)
+		m.log.Debug("Removed Tweet ID \"%X\" due to timeout!", o[i].ID)
 	}
+	t.lock.Lock()
 	t.current = c
-}
-func (s *subscription) update(x context.Context, m *Manager) {
-	defer func(l logx.Log) {
-		if err := recover(); err != nil {
-			l.Error("Game subscription update function recovered from a panic: %s!", err)
-		}
-	}(m.log)
-	for len(s.new) > 0 {
-		s.clients = append(s.clients, &stream{<-s.new, true})
-	}
-	select {
-	case <-x.Done():
-		return
-	default:
-	}
-	m.log.Debug("Checking for update for subscribed Game %d..", s.ID)
-	var g game
-	if err := m.getJSON(x, "api/scoreboard/"+strconv.FormatUint(s.ID, 10), &g); err != nil {
-		m.log.Error("Error retrieving data for Game ID %d: %s!", s.ID, err.Error())
-		return
-	}
-	g.Meta.ID = s.ID
-	for i := range m.Games {
-		if m.Games[i].ID == g.Meta.ID {
-			g.Meta.End = m.Games[i].End
-			g.Meta.Start = m.Games[i].Start
-			g.Meta.Status = m.Games[i].Status
-			break
-		}
-	}
-	if m.twitter != nil {
-		g.Tweets = m.twitter.current
-	}
-	select {
-	case <-x.Done():
-		return
-	default:
-	}
-	var u []update
-	m.log.Debug("Running game comparison on Game %d..", s.ID)
-	s.cache, u = g.Delta(m.assets, &s.last)
-	s.last = g
-	if len(u) > 0 {
-		m.log.Debug("%d Updates detected in Game %d, updating clients..", len(u), s.ID)
-		r := make([]*stream, 0, len(s.clients))
-		for i := range s.clients {
-			select {
-			case <-x.Done():
-				return
-			default:
-			}
-			if i > len(s.clients) {
-				return
-			}
-			if !s.clients[i].ok {
-				s.clients[i].Close()
-				continue
-			}
-			s.clients[i].ok = false
-			if err := s.clients[i].WriteJSON(u); err != nil {
-				m.log.Error(`Received error by client "%s", removing: %s!`, s.clients[i].RemoteAddr().String(), err.Error())
-				s.clients[i].Close()
-				continue
-			}
-			s.clients[i].ok = true
-			r = append(r, s.clients[i])
-		}
-		s.clients = r
-	}
+	t.lock.Unlock()
 }
 
 // Twitter creates and returns the Twitter channel. This channel can be used to submit Tweets to
-// be sent to the scoreboard.
+// be sent to the scoreboard. This function must be called before 'Start'.
 func (m *Manager) Twitter(t time.Duration) chan<- *twitter.Tweet {
 	m.twitter = &tweets{new: make(chan *twitter.Tweet), timeout: t}
 	return m.twitter.new
 }
-func (m Manager) get(x context.Context, u string) ([]byte, error) {
-	m.url.Path = path.Join(m.url.Path, u) + "/"
+func (m *Manager) url(u string) string {
+	v := m.base
+	v.Path = path.Join(v.Path, u) + "/"
+	return v.String()
+}
+
+// get requests the Scorebot URL and returns the response body and its hash. Requests are
+// made conditional on the last response for the same URL, so a 'Not Modified' response
+// will return the last body and hash instead. In replay mode, the body is served from
+// the capture files instead.
+func (m *Manager) get(x context.Context, u string) ([]byte, uint64, error) {
+	if m.replay != nil {
+		return m.replay.get(path.Join(u))
+	}
 	var (
+		v      = m.url(u)
 		c, f   = context.WithTimeout(x, m.timeout)
-		r, err = http.NewRequestWithContext(c, http.MethodGet, m.url.String(), nil)
+		r, err = http.NewRequestWithContext(c, http.MethodGet, v, nil)
 	)
 	defer f()
 	if err != nil {
-		return nil, err
+		return nil, 0, err
+	}
+	if !m.breaker.allow() {
+		return nil, 0, errOffline
 	}
+	m.auth.apply(r)
+	e := m.cache.prepare(v, r)
 	o, err := m.client.Do(r)
 	if err != nil {
-		return nil, err
+		// NOTE(dij): A cancelled parent context (the Manager stopping, an ingest
+		//            request or a client hanging up) says nothing about Scorebot, so
+		//            it does not count against the breaker. Only our own timeout does.
+		if x.Err() != nil {
+			m.breaker.release()
+		} else {
+			m.failure()
+		}
+		return nil, 0, err
 	}
 	if o.Body == nil {
-		return nil, errors.New(`request "` + m.url.String() + `" returned an empty body`)
+		m.failure()
+		return nil, 0, errors.New(`request "` + v + `" returned an empty body`)
 	}
 	defer o.Body.Close()
+	// NOTE(dij): Only server errors count against the breaker, as a client error
+	//            (like a missing Game) means Scorebot itself is up.
+	if o.StatusCode >= 500 {
+		m.failure()
+	} else {
+		m.success()
+	}
+	if o.StatusCode == http.StatusNotModified && e != nil {
+		atomic.AddUint64(&m.cache.modified, 1)
+		m.record(path.Join(u), nil)
+		return e.body, e.sum, nil
+	}
 	if o.StatusCode >= 400 {
-		return nil, errors.New(`request "` + m.url.String() + `" returned status code ` + strconv.Itoa(o.StatusCode))
+		return nil, 0, errors.New(`request "` + v + `" returned status code ` + strconv.Itoa(o.StatusCode))
 	}
 	b, err := io.ReadAll(o.Body)
 	if err != nil {
-		return nil, errors.New(`error reading from the URL "` + m.url.String() + `": ` + err.Error())
+		return nil, 0, errors.New(`error reading from the URL "` + v + `": ` + err.Error())
+	}
+	h, ok := m.cache.store(v, o, b)
+	if ok {
+		m.record(path.Join(u), b)
+	} else {
+		m.record(path.Join(u), nil)
 	}
-	return b, nil
+	return b, h, nil
 }
-func (m Manager) getJSON(x context.Context, u string, o interface{}) error {
-	r, err := m.get(x, u)
+
+func (m *Manager) success() {
+	if m.breaker.success() {
+		m.log.Info("Scorebot is reachable again, resuming updates.")
+		m.upstream()
+	}
+}
+func (m *Manager) failure() {
+	if m.breaker.failure() {
+		m.log.Warning("Scorebot is unreachable, backing off and marking clients as delayed!")
+		m.upstream()
+	}
+}
+
+// upstream sends the Scorebot reachability state to all the subscription clients.
+func (m *Manager) upstream() {
+	m.lock.RLock()
+	l := make([]*subscription, 0, len(m.subs))
+	for _, s := range m.subs {
+		l = append(l, s)
+	}
+	m.lock.RUnlock()
+	for _, s := range l {
+		s.control(m)
+	}
+}
+
+// getJSON requests the Scorebot URL and unmarshals the response into the supplied value.
+// The unmarshal is skipped if the response hash matches the supplied last hash, which is
+// signaled by returning false. A last hash of zero will always unmarshal.
+func (m *Manager) getJSON(x context.Context, u string, last uint64, o interface{}) (uint64, bool, error) {
+	r, h, err := m.get(x, u)
 	if err != nil {
-		return err
+		return 0, false, err
+	}
+	if last != 0 && h == last {
+		return h, false, nil
 	}
 	if err := json.Unmarshal(r, &o); err != nil {
-		return errors.New(`unable to unmarshal JSON from "` + u + `": ` + err.Error())
+		return 0, false, errors.New(`unable to unmarshal JSON from "` + u + `": ` + err.Error())
 	}
-	return nil
+	return h, true, nil
+}
+
+// CacheStats returns the current Scorebot request cache counters.
+func (m *Manager) CacheStats() CacheStats {
+	return m.cache.stats()
 }
 
 // New creates a collection instance from the provided logger, timeout and API URL endpoint.
@@ -465,7 +692,8 @@ func New(burl, d string, tick, t time.Duration, l logx.Log) (*Manager, error) {
 	}
 	m := &Manager{
 		log:    l,
-		url:    *u,
+		base:   *u,
+		cache:  cache{e: make(map[string]*response)},
 		subs:   make(map[uint64]*subscription),
 		tick:   time.NewTicker(tick),
 		active: make(map[string]uint64),
@@ -481,7 +709,9 @@ func New(burl, d string, tick, t time.Duration, l logx.Log) (*Manager, error) {
 				ResponseHeaderTimeout: t,
 			},
 		},
-		timeout: t,
+		timeout:  t,
+		workers:  1,
+		interval: tick,
 	}
 	return m, nil
 }
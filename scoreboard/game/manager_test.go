@@ -0,0 +1,326 @@
+// Copyright(C) 2020 - 2023 iDigitalFlame
+//
+// This program is free software: you can redistribute it and / or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, either version 3 of the License, or
+// any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.If not, see <https://www.gnu.org/licenses/>.
+//
+
+package game
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/PurpleSec/logx"
+	"github.com/gorilla/websocket"
+)
+
+type fakeScorebot struct {
+	*httptest.Server
+	ticks uint64
+}
+
+func newFakeScorebot(t *testing.T) *fakeScorebot {
+	f := new(fakeScorebot)
+	f.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.URL.Path == "/api/games/":
+			w.Write([]byte(`[{"id":1,"name":"Test Game","mode":0,"status":1,"start":"2023-01-01T00:00:00Z","end":"0001-01-01T00:00:00Z"}]`))
+		case strings.HasPrefix(r.URL.Path, "/api/scoreboard/1"):
+			w.Write([]byte(testGame(atomic.LoadUint64(&f.ticks))))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(f.Close)
+	return f
+}
+func testGame(n uint64) string {
+	v := strconv.FormatUint(n, 10)
+	return `{"name":"Test Game","mode":0,"message":"Hello","credit":"","teams":[` +
+		`{"id":1,"name":"Blue-1","logo":"","color":"#0000FF","score":{"total":` + v + `,"health":100},` +
+		`"flags":{"open":1,"lost":0,"captured":0},"tickets":{"open":0,"closed":0},"beacons":[],` +
+		`"hosts":[{"id":1,"name":"www","online":true,"services":[{"id":1,"port":80,"status":"green","protocol":"tcp"}]}]},` +
+		`{"id":2,"name":"Blue-2","logo":"","color":"#00FF00","score":{"total":5,"health":100},` +
+		`"flags":{"open":1,"lost":0,"captured":0},"tickets":{"open":0,"closed":0},"beacons":[{"id":1,"team":1,"color":"#FF0000"}],` +
+		`"hosts":[{"id":2,"name":"mail","online":true,"services":[{"id":2,"port":25,"status":"red","protocol":"tcp"}]}]}` +
+		`],"events":[]}`
+}
+func newTestManager(t *testing.T, f *fakeScorebot) (*Manager, string) {
+	m, err := New(f.URL, "", time.Hour, 5*time.Second, logx.NOP)
+	if err != nil {
+		t.Fatalf("New failed: %s", err)
+	}
+	u := websocket.Upgrader{CheckOrigin: func(_ *http.Request) bool { return true }}
+	w := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := u.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		m.New(c)
+	}))
+	t.Cleanup(w.Close)
+	t.Cleanup(m.close)
+	return m, "ws" + strings.TrimPrefix(w.URL, "http")
+}
+func dialTest(t *testing.T, u string, h map[string]uint64) (*websocket.Conn, message) {
+	c, _, err := websocket.DefaultDialer.Dial(u, nil)
+	if err != nil {
+		t.Errorf("Dial failed: %s", err)
+		return nil, message{}
+	}
+	if err = c.WriteJSON(h); err != nil {
+		t.Errorf("WriteJSON failed: %s", err)
+		c.Close()
+		return nil, message{}
+	}
+	var v message
+	c.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if err = c.ReadJSON(&v); err != nil {
+		t.Errorf("ReadJSON failed: %s", err)
+		c.Close()
+		return nil, message{}
+	}
+	return c, v
+}
+
+func TestManagerConcurrent(t *testing.T) {
+	var (
+		f    = newFakeScorebot(t)
+		m, u = newTestManager(t, f)
+		x, c = context.WithCancel(context.Background())
+		w    sync.WaitGroup
+	)
+	defer c()
+	w.Add(1)
+	go func() {
+		defer w.Done()
+		for i := 0; i < 50; i++ {
+			atomic.AddUint64(&f.ticks, 1)
+			m.update(x)
+		}
+	}()
+	w.Add(1)
+	go func() {
+		defer w.Done()
+		for i := 0; i < 200; i++ {
+			m.Game("test game")
+			for _, g := range m.Games() {
+				_ = g.Name
+			}
+		}
+	}()
+	for i := 0; i < 16; i++ {
+		w.Add(1)
+		go func(i int) {
+			defer w.Done()
+			for j := 0; j < 5; j++ {
+				n, v := dialTest(t, u, map[string]uint64{"game": 1})
+				if n == nil {
+					return
+				}
+				if !v.Full || len(v.Updates) == 0 {
+					t.Errorf("Expected a full snapshot on connect, got %+v", v)
+				}
+				if (i+j)%2 == 0 {
+					n.SetReadDeadline(time.Now().Add(50 * time.Millisecond))
+					n.ReadMessage()
+				}
+				n.Close()
+			}
+		}(i)
+	}
+	w.Wait()
+	c()
+	m.close()
+	if _, err := m.subscribe(1); err != errClosed {
+		t.Fatalf("Expected errClosed after close, got %v", err)
+	}
+}
+func TestManagerResume(t *testing.T) {
+	var (
+		f    = newFakeScorebot(t)
+		m, u = newTestManager(t, f)
+		x    = context.Background()
+	)
+	n, v := dialTest(t, u, map[string]uint64{"game": 1})
+	if n == nil {
+		t.FailNow()
+	}
+	atomic.AddUint64(&f.ticks, 1)
+	m.update(x)
+	n.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if err := n.ReadJSON(&v); err != nil {
+		t.Fatalf("ReadJSON failed: %s", err)
+	}
+	if v.Full || v.Seq != 1 || len(v.Updates) == 0 {
+		t.Fatalf("Expected delta with seq 1, got %+v", v)
+	}
+	n.Close()
+	atomic.AddUint64(&f.ticks, 1)
+	m.update(x)
+	n, r := dialTest(t, u, map[string]uint64{"game": 1, "seq": v.Seq, "epoch": v.Epoch})
+	if n == nil {
+		t.FailNow()
+	}
+	defer n.Close()
+	if r.Full || r.Seq != 2 || len(r.Updates) == 0 {
+		t.Fatalf("Expected resumed delta with seq 2, got %+v", r)
+	}
+	n2, r := dialTest(t, u, map[string]uint64{"game": 1, "seq": v.Seq, "epoch": v.Epoch + 1})
+	if n2 == nil {
+		t.FailNow()
+	}
+	defer n2.Close()
+	if !r.Full {
+		t.Fatalf("Expected full snapshot on epoch mismatch, got %+v", r)
+	}
+}
+func TestManagerOffense(t *testing.T) {
+	var (
+		f    = newFakeScorebot(t)
+		_, u = newTestManager(t, f)
+	)
+	b, v := dialTest(t, u, map[string]uint64{"game": 1})
+	if b == nil {
+		t.FailNow()
+	}
+	defer b.Close()
+	if hasUpdate(v.Updates, "game-offense-a1", "", "") {
+		t.Fatalf("Board client should not receive the offense updates")
+	}
+	o, v := dialTest(t, u, map[string]uint64{"game": 1, "offense": 1})
+	if o == nil {
+		t.FailNow()
+	}
+	defer o.Close()
+	if !hasUpdate(v.Updates, "game-offense-a1", "", "") {
+		t.Fatalf("Offense client did not receive the offense updates")
+	}
+}
+func TestManagerExpire(t *testing.T) {
+	var (
+		f    = newFakeScorebot(t)
+		m, u = newTestManager(t, f)
+		x    = context.Background()
+	)
+	n, _ := dialTest(t, u, map[string]uint64{"game": 1})
+	if n == nil {
+		t.FailNow()
+	}
+	n.Close()
+	for i := 0; i < 20; i++ {
+		m.update(x)
+		m.lock.RLock()
+		_, ok := m.subs[1]
+		m.lock.RUnlock()
+		if !ok {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("Subscription was not removed after its clients left")
+}
+func TestManagerState(t *testing.T) {
+	var (
+		d = make(chan struct{})
+		f = &fakeScorebot{Server: httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// NOTE(dij): Scorebot is down and requests hang until they time out.
+			select {
+			case <-d:
+			case <-r.Context().Done():
+			}
+		}))}
+		s = t.TempDir()
+	)
+	t.Cleanup(f.Close)
+	t.Cleanup(func() { close(d) })
+	if err := os.WriteFile(filepath.Join(s, stateGame(1)), []byte(testGame(7)), 0640); err != nil {
+		t.Fatal(err)
+	}
+	m, u := newTestManager(t, f)
+	if err := m.SetState(s); err != nil {
+		t.Fatalf("SetState failed: %s", err)
+	}
+	e := time.Now()
+	n, v := dialTest(t, u, map[string]uint64{"game": 1})
+	if n == nil {
+		t.FailNow()
+	}
+	defer n.Close()
+	if time.Since(e) > time.Second {
+		t.Fatalf("Saved state took %s to be served", time.Since(e))
+	}
+	if !v.Full || !v.Stale || !hasUpdate(v.Updates, "game-team-t1-name-total", "", "7") {
+		t.Fatalf("Expected the saved state marked as stale, got %+v", v)
+	}
+}
+func TestManagerSlowGame(t *testing.T) {
+	var (
+		n uint32
+		f = &fakeScorebot{Server: httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// NOTE(dij): Game 2 hangs past the timeout once it's slow, the others
+			//            return the current tick right away.
+			if r.URL.Path == "/api/scoreboard/2/" && atomic.LoadUint32(&n) == 1 {
+				select {
+				case <-time.After(5 * time.Second):
+				case <-r.Context().Done():
+				}
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			if r.URL.Path == "/api/games/" {
+				w.Write([]byte(`[]`))
+				return
+			}
+			w.Write([]byte(testGame(uint64(atomic.LoadUint32(&n)))))
+		}))}
+	)
+	t.Cleanup(f.Close)
+	m, err := New(f.URL, "", time.Hour, 250*time.Millisecond, logx.NOP)
+	if err != nil {
+		t.Fatalf("New failed: %s", err)
+	}
+	t.Cleanup(m.close)
+	m.SetWorkers(1)
+	for i := uint64(1); i <= 5; i++ {
+		if _, err := m.subscribe(i); err != nil {
+			t.Fatalf("Subscribe to Game %d failed: %s", i, err)
+		}
+	}
+	atomic.StoreUint32(&n, 1)
+	m.startUpdate(context.Background())
+	for i := uint64(1); i <= 5; i++ {
+		m.lock.RLock()
+		s := m.subs[i]
+		m.lock.RUnlock()
+		s.lock.Lock()
+		v := s.last.Teams[0].Score.Total
+		s.lock.Unlock()
+		if i == 2 && v != 0 {
+			t.Fatalf("Slow Game 2 should not have been updated")
+		}
+		if i != 2 && v != 1 {
+			t.Fatalf("Game %d was not updated in the same tick as the slow Game", i)
+		}
+	}
+}
@@ -0,0 +1,277 @@
+// Copyright(C) 2020 - 2023 iDigitalFlame
+//
+// This program is free software: you can redistribute it and / or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, either version 3 of the License, or
+// any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.If not, see <https://www.gnu.org/licenses/>.
+//
+
+package game
+
+import (
+	"errors"
+	"html"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// localEvent is set on the ID of every event created by a milestone, so they can never
+// collide with the IDs of events from Scorebot.
+const localEvent uint64 = 1 << 63
+
+const (
+	firstFlag uint8 = iota
+	firstBeacon
+	leadChange
+	fullOutage
+	zeroHealth
+)
+
+// Milestone is a rule that creates an event when something notable happens in a Game.
+//
+// The Type can be "first_flag", "first_beacon", "lead_change", "full_outage" or
+// "zero_health". The Title and Text are templates that can use the values "{{.Game}}",
+// "{{.Team}}", "{{.Other}}" and "{{.Value}}" (the flags captured, lead margin or
+// health). If Window is true, the event is shown as a popup window, otherwise it is
+// shown as a console message. The Duration is how long the event is shown, in seconds.
+type Milestone struct {
+	Type     string `json:"type"`
+	Title    string `json:"title,omitempty"`
+	Text     string `json:"text"`
+	Duration int    `json:"duration"`
+	Window   bool   `json:"window"`
+}
+
+// local is an event created by a milestone that is shown until the expire time.
+type local struct {
+	expire time.Time
+	event
+}
+type rule struct {
+	text   *template.Template
+	title  *template.Template
+	expire time.Duration
+	kind   uint8
+	window bool
+}
+
+// details are the values that can be used in a milestone template. The Team is the Team
+// the milestone is about and Other is the second Team involved (if any), such as the
+// Team that lost the lead or the Team that placed a beacon.
+type details struct {
+	Game  string
+	Team  string
+	Other string
+	Value int64
+}
+
+// milestones holds the milestone state of a single Game. This is guarded by the
+// subscription lock.
+type milestones struct {
+	active  []local
+	beacons map[uint64]struct{}
+	down    map[uint64]struct{}
+	zero    map[uint64]struct{}
+	leader  uint64
+	next    uint64
+	flag    bool
+	primed  bool
+}
+
+func outage(t *team) bool {
+	var n int
+	for i := range t.Hosts {
+		for x := range t.Hosts[i].Services {
+			if t.Hosts[i].Services[x].State != red {
+				return false
+			}
+			n++
+		}
+	}
+	return n > 0
+}
+func (r rule) render(d details) (event, error) {
+	var b strings.Builder
+	if err := r.text.Execute(&b, d); err != nil {
+		return event{}, err
+	}
+	e := event{Data: map[string]string{"text": b.String()}}
+	if !r.window {
+		return e, nil
+	}
+	if e.Type = 1; r.title != nil {
+		b.Reset()
+		if err := r.title.Execute(&b, d); err != nil {
+			return event{}, err
+		}
+		e.Data["title"] = b.String()
+	}
+	return e, nil
+}
+
+// expired returns true if any of the milestone events should no longer be shown.
+func (s *milestones) expired(t time.Time) bool {
+	for i := range s.active {
+		if !t.Before(s.active[i].expire) {
+			return true
+		}
+	}
+	return false
+}
+
+// check looks for any milestones reached in the Game and creates events for them. Any
+// milestone events that are still being shown are added to the Game events, so they go
+// through the same comparison as events from Scorebot. The first check only records
+// the current state, so milestones reached before the Game was watched are not shown.
+func (s *milestones) check(m *Manager, g *game, t time.Time) {
+	if len(m.rules) == 0 {
+		return
+	}
+	if s.beacons == nil {
+		s.beacons = make(map[uint64]struct{})
+		s.down, s.zero = make(map[uint64]struct{}), make(map[uint64]struct{})
+	}
+	var (
+		l, p, c *team
+		n       = make(map[uint64]string, len(g.Teams))
+	)
+	for i := range g.Teams {
+		n[g.Teams[i].ID] = g.Teams[i].Name
+	}
+	for i := range g.Teams {
+		v := &g.Teams[i]
+		if v.Flags.Captured > 0 && (c == nil || v.Flags.Captured > c.Flags.Captured) {
+			c = v
+		}
+		if v.Offense {
+			continue
+		}
+		if _, ok := s.beacons[v.ID]; !ok && len(v.Beacons) > 0 {
+			s.beacons[v.ID] = struct{}{}
+			s.fire(m, g, t, firstBeacon, details{Team: v.Name, Other: n[v.Beacons[0].Team]})
+		}
+		if _, ok := s.down[v.ID]; ok != outage(v) {
+			if !ok {
+				s.down[v.ID] = struct{}{}
+				s.fire(m, g, t, fullOutage, details{Team: v.Name})
+			} else {
+				delete(s.down, v.ID)
+			}
+		}
+		if _, ok := s.zero[v.ID]; ok != (v.Score.Health <= 0) {
+			if !ok {
+				s.zero[v.ID] = struct{}{}
+				s.fire(m, g, t, zeroHealth, details{Team: v.Name, Value: v.Score.Health})
+			} else {
+				delete(s.zero, v.ID)
+			}
+		}
+		if v.ID == s.leader {
+			p = v
+		}
+		if l == nil || v.value(m.ranking.metric) > l.value(m.ranking.metric) {
+			l = v
+		}
+	}
+	if c != nil && !s.flag {
+		s.flag = true
+		s.fire(m, g, t, firstFlag, details{Team: c.Name, Value: int64(c.Flags.Captured)})
+	}
+	switch {
+	case l == nil:
+	case p == nil:
+		s.leader = l.ID
+	case l.value(m.ranking.metric) > p.value(m.ranking.metric):
+		// NOTE(dij): The leader only changes when another Team is strictly ahead
+		//            of it, so a tie for the lead does not create an event.
+		s.fire(m, g, t, leadChange, details{Team: l.Name, Other: p.Name, Value: l.value(m.ranking.metric) - p.value(m.ranking.metric)})
+		s.leader = l.ID
+	}
+	s.primed = true
+	x := s.active[:0]
+	for i := range s.active {
+		if t.Before(s.active[i].expire) {
+			x = append(x, s.active[i])
+		}
+	}
+	s.active = x
+	for i := range s.active {
+		g.Events.Current = append(g.Events.Current, s.active[i].event)
+	}
+}
+func (s *milestones) fire(m *Manager, g *game, t time.Time, k uint8, d details) {
+	if !s.primed {
+		return
+	}
+	for _, r := range m.rules {
+		if r.kind != k {
+			continue
+		}
+		v := d
+		if v.Game = g.Meta.Name; r.window {
+			// NOTE(dij): Window event text is shown as HTML, so the names need to be
+			//            escaped.
+			v.Game, v.Team, v.Other = html.EscapeString(v.Game), html.EscapeString(v.Team), html.EscapeString(v.Other)
+		}
+		e, err := r.render(v)
+		if err != nil {
+			m.log.Warning("Could not create milestone event for Game %d: %s!", g.Meta.ID, err.Error())
+			continue
+		}
+		s.next++
+		e.ID = localEvent | s.next
+		s.active = append(s.active, local{event: e, expire: t.Add(r.expire)})
+		m.log.Debug(`Created milestone event "%s" for Game %d.`, e.Data["text"], g.Meta.ID)
+	}
+}
+
+// SetMilestones sets the rules used to create events when milestones are reached in a
+// Game. An empty list disables milestone events. This function must be called before
+// 'Start'.
+func (m *Manager) SetMilestones(v []Milestone) error {
+	r := make([]rule, 0, len(v))
+	for i := range v {
+		var x rule
+		switch strings.ToLower(v[i].Type) {
+		case "first_flag":
+			x.kind = firstFlag
+		case "first_beacon":
+			x.kind = firstBeacon
+		case "lead_change":
+			x.kind = leadChange
+		case "full_outage":
+			x.kind = fullOutage
+		case "zero_health":
+			x.kind = zeroHealth
+		default:
+			return errors.New(`invalid milestone type "` + v[i].Type + `"`)
+		}
+		if len(v[i].Text) == 0 {
+			return errors.New(`milestone "` + v[i].Type + `" text cannot be empty`)
+		}
+		var err error
+		if x.text, err = template.New(v[i].Type).Parse(v[i].Text); err != nil {
+			return errors.New(`milestone "` + v[i].Type + `" text: ` + err.Error())
+		}
+		if len(v[i].Title) > 0 {
+			if x.title, err = template.New(v[i].Type).Parse(v[i].Title); err != nil {
+				return errors.New(`milestone "` + v[i].Type + `" title: ` + err.Error())
+			}
+		}
+		if x.window, x.expire = v[i].Window, time.Duration(v[i].Duration)*time.Second; x.expire <= 0 {
+			x.expire = time.Second * 30
+		}
+		r = append(r, x)
+	}
+	m.rules = r
+	return nil
+}
@@ -0,0 +1,183 @@
+// Copyright(C) 2020 - 2023 iDigitalFlame
+//
+// This program is free software: you can redistribute it and / or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, either version 3 of the License, or
+// any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.If not, see <https://www.gnu.org/licenses/>.
+//
+
+package game
+
+import (
+	"sort"
+	"strconv"
+)
+
+// attack is the activity of a single attacking Team, which is any Team marked as offense
+// or any Team that holds a beacon on another Team.
+type attack struct {
+	Name    string
+	Color   string
+	Targets map[uint64]target
+	ID      uint64
+	Flags   uint32
+	Beacons int
+}
+
+// target is a Team holding beacons from an attacking Team.
+type target struct {
+	Name  string
+	Count int
+}
+
+// attacks returns the attacking Teams in the Game, sorted by ID. The result is never nil,
+// so a Game without attackers is not built again.
+func (g *game) attacks() []attack {
+	var (
+		o = make([]attack, 0)
+		x = make(map[uint64]int)
+		n = make(map[uint64]*team, len(g.Teams))
+	)
+	for i := range g.Teams {
+		n[g.Teams[i].ID] = &g.Teams[i]
+	}
+	add := func(t *team) int {
+		if v, ok := x[t.ID]; ok {
+			return v
+		}
+		x[t.ID] = len(o)
+		o = append(o, attack{ID: t.ID, Name: t.Name, Color: t.Color, Flags: t.Flags.Captured, Targets: make(map[uint64]target)})
+		return len(o) - 1
+	}
+	for i := range g.Teams {
+		if g.Teams[i].Offense {
+			add(&g.Teams[i])
+		}
+	}
+	for i := range g.Teams {
+		for _, b := range g.Teams[i].Beacons {
+			t, ok := n[b.Team]
+			if !ok || b.Team == g.Teams[i].ID {
+				continue
+			}
+			// NOTE(dij): 'add' can grow the slice, so it's called before the
+			//            slice is indexed.
+			k := add(t)
+			v := &o[k]
+			c := v.Targets[g.Teams[i].ID]
+			c.Name, c.Count = g.Teams[i].Name, c.Count+1
+			v.Beacons++
+			v.Targets[g.Teams[i].ID] = c
+		}
+	}
+	sort.Slice(o, func(i, j int) bool { return o[i].ID < o[j].ID })
+	return o
+}
+
+// compareOffense adds the attacking Team stats for the offense view. The attackers are
+// kept on the Game, so the old Game does not need to build them again.
+func (g *game) compareOffense(p *planner, o *game) {
+	var (
+		b = make(map[uint64]attack)
+		r []attack
+	)
+	if g.attackers == nil {
+		g.attackers = g.attacks()
+	}
+	if o != nil {
+		if o.attackers == nil {
+			o.attackers = o.attacks()
+		}
+		r = o.attackers
+	}
+	for _, v := range r {
+		b[v.ID] = v
+	}
+	p.Prefix(p.prefix + "-offense")
+	for _, v := range g.attackers {
+		v.compare(p, b[v.ID])
+		delete(b, v.ID)
+	}
+	for _, v := range r {
+		if _, ok := b[v.ID]; ok {
+			p.Remove("a" + strconv.FormatUint(v.ID, 10))
+		}
+	}
+	p.rollbackPrefix()
+}
+func (a attack) compare(p *planner, o attack) {
+	i := "a" + strconv.FormatUint(a.ID, 10)
+	if o.ID == 0 {
+		p.DeltaValue(i, "", "offense-team")
+	} else {
+		p.Value(i, "", "offense-team")
+	}
+	p.Prefix(p.prefix + "-" + i)
+	if o.Name == a.Name && o.Color == a.Color {
+		p.Value("name", a.Name, "offense-name")
+		p.Property("", a.Color, "border-color")
+	} else {
+		p.DeltaValue("name", a.Name, "offense-name")
+		p.DeltaProperty("", a.Color, "border-color")
+	}
+	if o.ID != 0 && o.Flags == a.Flags && o.Beacons == a.Beacons && len(o.Targets) == len(a.Targets) {
+		p.Value("flags", a.Flags, "offense-stat offense-flags")
+		p.Value("beacons", a.Beacons, "offense-stat offense-beacons")
+		p.Value("affected", len(a.Targets), "offense-stat offense-affected")
+	} else {
+		p.DeltaValue("flags", a.Flags, "offense-stat offense-flags")
+		p.DeltaValue("beacons", a.Beacons, "offense-stat offense-beacons")
+		p.DeltaValue("affected", len(a.Targets), "offense-stat offense-affected")
+	}
+	if o.ID == 0 {
+		p.DeltaValue("targets", "", "offense-targets")
+	} else {
+		p.Value("targets", "", "offense-targets")
+	}
+	t := make([]uint64, 0, len(a.Targets))
+	for k := range a.Targets {
+		t = append(t, k)
+	}
+	sort.Slice(t, func(i, j int) bool { return t[i] < t[j] })
+	for _, k := range t {
+		var (
+			x     = "targets-d" + strconv.FormatUint(k, 10)
+			v     = a.Targets[k]
+			c, ok = o.Targets[k]
+		)
+		if ok {
+			p.Value(x, "", "offense-target")
+		} else {
+			p.DeltaValue(x, "", "offense-target")
+		}
+		if ok && c.Name == v.Name {
+			p.Value(x+"-name", v.Name, "offense-target-name")
+		} else {
+			p.DeltaValue(x+"-name", v.Name, "offense-target-name")
+		}
+		if ok && c.Count == v.Count {
+			p.Value(x+"-count", v.Count, "offense-target-count")
+		} else {
+			p.DeltaValue(x+"-count", v.Count, "offense-target-count")
+		}
+	}
+	r := make([]uint64, 0, len(o.Targets))
+	for k := range o.Targets {
+		if _, ok := a.Targets[k]; !ok {
+			r = append(r, k)
+		}
+	}
+	sort.Slice(r, func(i, j int) bool { return r[i] < r[j] })
+	for _, k := range r {
+		p.Remove("targets-d" + strconv.FormatUint(k, 10))
+	}
+	p.rollbackPrefix()
+}
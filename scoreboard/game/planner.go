@@ -17,7 +17,7 @@
 package game
 
 import (
-	"fmt"
+	"sort"
 	"strconv"
 )
 
@@ -25,7 +25,7 @@ type delta struct {
 	A, B interface{}
 }
 type update struct {
-	Value  interface{}       `json:"value,omitempty"`
+	Value  *string           `json:"value,omitempty"`
 	Data   map[string]string `json:"data"`
 	ID     string            `json:"id"`
 	Name   string            `json:"name,omitempty"`
@@ -33,11 +33,37 @@ type update struct {
 	Event  bool              `json:"event"`
 	Remove bool              `json:"remove"`
 }
+type message struct {
+	Updates []update `json:"updates"`
+	Seq     uint64   `json:"seq"`
+	Epoch   uint64   `json:"epoch"`
+	Fetched int64    `json:"fetched"`
+	Server  int64    `json:"server"`
+	Tick    int64    `json:"tick"`
+	Full    bool     `json:"full"`
+	Stale   bool     `json:"stale"`
+	Points  []sample `json:"points,omitempty"`
+	Notices []notice `json:"notices,omitempty"`
+	Offline bool     `json:"offline"`
+}
+
+// planner collects the updates made when comparing two Game states. The updates are
+// always added in the same order, so the same states create the same updates. An item
+// adds its own container and values before its nested items and children, and sibling
+// items (Teams, Hosts, Services, Beacons, events and tweets) are added in order of their
+// IDs, followed by the removed siblings, also in order of their IDs.
 type planner struct {
-	prefix string
-	Delta  []update
-	Create []update
-	last   []string
+	names    map[uint64]string
+	prefix   string
+	host     string
+	Delta    []update
+	Create   []update
+	last     []string
+	old      []update
+	block    []string
+	notices  []notice
+	team     uint64
+	announce kinds
 }
 type comparable interface {
 	Sum() uint64
@@ -66,9 +92,22 @@ func (c compare) Two(d comparable) {
 	}
 	v.B = d
 }
+
+// Keys returns the IDs in the compare map in order.
+func (c compare) Keys() []uint64 {
+	k := make([]uint64, 0, len(c))
+	for v := range c {
+		k = append(k, v)
+	}
+	sort.Slice(k, func(i, j int) bool { return k[i] < k[j] })
+	return k
+}
 func (p *planner) rollbackPrefix() {
 	p.prefix, p.last = p.last[len(p.last)-1], p.last[:len(p.last)-1]
 }
+
+// printStr returns the value as a string without using 'fmt'. Other types are never
+// used in updates and return an empty string.
 func printStr(v interface{}) string {
 	var s string
 	switch i := v.(type) {
@@ -100,74 +139,87 @@ func printStr(v interface{}) string {
 		s = strconv.FormatFloat(float64(i), 'f', 2, 32)
 	case float64:
 		s = strconv.FormatFloat(i, 'f', 2, 64)
-	default:
-		// NOTE(dij): Should we remove this? It seems like it's not called.
-		//            Could remove a call to the heavy 'fmt' package.
-		s = fmt.Sprintf("%s", v)
+	case bool:
+		s = strconv.FormatBool(i)
+	case stringer:
+		s = i.String()
 	}
 	return s
 }
 func (p *planner) Remove(i interface{}) {
-	u := update{ID: printStr(i), Remove: true}
-	if len(u.ID) == 0 {
-		u.ID = p.prefix
-	} else if len(p.prefix) > 0 {
-		u.ID = p.prefix + "-" + u.ID
-	}
+	u := update{ID: p.join(printStr(i)), Remove: true}
 	p.Delta = append(p.Delta, u)
 }
+
+// slots returns n strings cut from a shared block, so the update values of a Delta do
+// not need an allocation each. The returned slice cannot grow into the rest of the
+// block.
+func (p *planner) slots(n int) []string {
+	if cap(p.block)-len(p.block) < n {
+		c := 1024
+		if n > c {
+			c = n
+		}
+		p.block = make([]string, 0, c)
+	}
+	i := len(p.block)
+	p.block = p.block[:i+n]
+	return p.block[i : i+n : i+n]
+}
+
+// text returns a pointer to the value, used as an update value. Updates without a value
+// leave it nil, so it is left out of the JSON, while empty values are still sent.
+func (p *planner) text(s string) *string {
+	v := p.slots(1)
+	v[0] = s
+	return &v[0]
+}
+
+// join returns the ID under the current prefix.
+func (p *planner) join(i string) string {
+	switch {
+	case len(i) == 0:
+		return p.prefix
+	case len(p.prefix) > 0:
+		return p.prefix + "-" + i
+	}
+	return i
+}
 func (p *planner) RemoveEvent(i uint64, t uint8) {
 	p.Delta = append(p.Delta, update{
-		ID:     strconv.Itoa(int(i)),
-		Value:  strconv.Itoa(int(t)),
+		ID:     strconv.FormatUint(i, 10),
+		Value:  p.text(strconv.FormatUint(uint64(t), 10)),
 		Event:  true,
 		Remove: true,
 	})
 }
-func (p *planner) Value(i, v interface{}, c string) {
-	u := update{ID: printStr(i), Value: printStr(v), Class: c}
-	if len(u.ID) == 0 {
-		u.ID = p.prefix
-	} else if len(p.prefix) > 0 {
-		u.ID = p.prefix + "-" + u.ID
+
+// add adds the update to the create list, and also to the delta list if d is true. The
+// update ID must already be joined to the prefix.
+func (p *planner) add(u update, d bool) {
+	if d {
+		p.Delta = append(p.Delta, u)
 	}
 	p.Create = append(p.Create, u)
 }
+func (p *planner) Value(i, v interface{}, c string) {
+	p.add(update{ID: p.join(printStr(i)), Value: p.text(printStr(v)), Class: c}, false)
+}
 func (p *planner) Property(i, v interface{}, s string) {
-	u := update{ID: printStr(i), Name: s, Value: printStr(v)}
-	if len(u.ID) == 0 {
-		u.ID = p.prefix
-	} else if len(p.prefix) > 0 {
-		u.ID = p.prefix + "-" + u.ID
-	}
-	p.Create = append(p.Create, u)
+	p.add(update{ID: p.join(printStr(i)), Name: s, Value: p.text(printStr(v))}, false)
 }
 func (p *planner) DeltaValue(i, v interface{}, c string) {
-	u := update{ID: printStr(i), Value: printStr(v), Class: c}
-	if len(u.ID) == 0 {
-		u.ID = p.prefix
-	} else if len(p.prefix) > 0 {
-		u.ID = p.prefix + "-" + u.ID
-	}
-	p.Delta = append(p.Delta, u)
-	p.Create = append(p.Create, u)
+	p.add(update{ID: p.join(printStr(i)), Value: p.text(printStr(v)), Class: c}, true)
 }
 func (p *planner) DeltaProperty(i, v interface{}, s string) {
-	u := update{ID: printStr(i), Name: s, Value: printStr(v)}
-	if len(u.ID) == 0 {
-		u.ID = p.prefix
-	} else if len(p.prefix) > 0 {
-		u.ID = p.prefix + "-" + u.ID
-	}
-	p.Delta = append(p.Delta, u)
-	p.Create = append(p.Create, u)
+	p.add(update{ID: p.join(printStr(i)), Name: s, Value: p.text(printStr(v))}, true)
 }
 func (p *planner) Event(i uint64, t uint8, d map[string]string) {
 	p.Create = append(p.Create, update{
-		ID:    strconv.Itoa(int(i)),
+		ID:    strconv.FormatUint(i, 10),
 		Data:  d,
 		Event: true,
-		Value: strconv.Itoa(int(t)),
+		Value: p.text(strconv.FormatUint(uint64(t), 10)),
 	})
 }
 func (p *planner) DeltaEvent(i uint64, t uint8, d map[string]string) {
@@ -175,7 +227,7 @@ func (p *planner) DeltaEvent(i uint64, t uint8, d map[string]string) {
 		ID:    strconv.FormatUint(i, 10),
 		Data:  d,
 		Event: true,
-		Value: strconv.FormatUint(uint64(t), 10),
+		Value: p.text(strconv.FormatUint(uint64(t), 10)),
 	}
 	p.Delta = append(p.Delta, u)
 	p.Create = append(p.Create, u)
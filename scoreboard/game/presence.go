@@ -0,0 +1,177 @@
+// Copyright(C) 2020 - 2023 iDigitalFlame
+//
+// This program is free software: you can redistribute it and / or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, either version 3 of the License, or
+// any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.If not, see <https://www.gnu.org/licenses/>.
+//
+
+package game
+
+import (
+	"sort"
+	"time"
+)
+
+// presenceSize is the max number of removed beacons kept in the timeline of each
+// subscribed Game. The oldest beacons are dropped first.
+const presenceSize = 1000
+
+// sighting is the lifetime of a single beacon. The times are in milliseconds, Gone is
+// zero while the beacon is still on the board and Last is when it was last seen, as a
+// beacon could have been removed any time between Last and Gone.
+type sighting struct {
+	First    int64  `json:"first"`
+	Last     int64  `json:"last"`
+	Gone     int64  `json:"gone,omitempty"`
+	ID       uint64 `json:"id"`
+	Target   uint64 `json:"target"`
+	Attacker uint64 `json:"attacker"`
+}
+
+// dwell is the aggregate beacon stats of a Team, either as a target or as an attacker.
+// The times are in seconds, and beacons still on the board count up to now.
+type dwell struct {
+	Name   string `json:"name"`
+	Team   uint64 `json:"team"`
+	Total  int64  `json:"total"`
+	Mean   int64  `json:"mean"`
+	Max    int64  `json:"max"`
+	Count  int    `json:"count"`
+	Active int    `json:"active"`
+}
+
+// presence holds the beacon lifetimes of a Game. Beacons seen when the Game was first
+// subscribed are counted from then, as the time they were placed is not known. This is
+// guarded by the subscription lock.
+type presence struct {
+	active map[uint64]*sighting
+	closed []sighting
+}
+type presenceGame struct {
+	Timeline  []sighting `json:"timeline"`
+	Targets   []dwell    `json:"targets"`
+	Attackers []dwell    `json:"attackers"`
+	Game      uint64     `json:"game"`
+}
+
+func (d *dwell) add(v sighting, n int64) {
+	e := v.Gone
+	if e == 0 {
+		e, d.Active = n, d.Active+1
+	}
+	x := (e - v.First) / 1000
+	if d.Count++; x > d.Max {
+		d.Max = x
+	}
+	d.Total += x
+	d.Mean = d.Total / int64(d.Count)
+}
+
+// observe records the beacons in the Game at the supplied time and sets the time each
+// beacon was first seen on it. Beacons that are no longer in the Game are moved to the
+// removed list.
+func (p *presence) observe(g *game, t time.Time) {
+	if p.active == nil {
+		p.active = make(map[uint64]*sighting)
+	}
+	var (
+		n = t.UnixMilli()
+		s = make(map[uint64]struct{}, len(p.active))
+	)
+	for i := range g.Teams {
+		for x := range g.Teams[i].Beacons {
+			b := &g.Teams[i].Beacons[x]
+			v, ok := p.active[b.ID]
+			if !ok {
+				v = &sighting{ID: b.ID, First: n, Target: g.Teams[i].ID, Attacker: b.Team}
+				p.active[b.ID] = v
+			}
+			if n > v.Last {
+				v.Last = n
+			}
+			s[b.ID], b.since = struct{}{}, v.First
+		}
+	}
+	for k, v := range p.active {
+		if _, ok := s[k]; ok {
+			continue
+		}
+		if v.Gone = n; len(p.closed) >= presenceSize {
+			p.closed = append(p.closed[:0], p.closed[len(p.closed)-presenceSize+1:]...)
+		}
+		p.closed = append(p.closed, *v)
+		delete(p.active, k)
+	}
+}
+func compareSince(p *planner, n, o int64) {
+	if n == 0 {
+		return
+	}
+	if n == o {
+		p.Property("", n, "data-since")
+		return
+	}
+	p.DeltaProperty("", n, "data-since")
+}
+
+// Beacons returns the beacon timeline and the dwell time stats of each Team, as a target
+// and as an attacker, in the subscribed Game and true if the Game is currently subscribed.
+// Beacons are only tracked for Games that clients are watching.
+func (m *Manager) Beacons(i uint64) (presenceGame, bool) {
+	m.lock.RLock()
+	s := m.subs[i]
+	m.lock.RUnlock()
+	if s == nil {
+		return presenceGame{}, false
+	}
+	n := time.Now().UnixMilli()
+	s.lock.Lock()
+	var (
+		o = presenceGame{Game: i, Timeline: make([]sighting, 0, len(s.beacons.closed)+len(s.beacons.active))}
+		x = make(map[uint64]string, len(s.last.Teams))
+	)
+	for _, t := range s.last.Teams {
+		x[t.ID] = t.Name
+	}
+	o.Timeline = append(o.Timeline, s.beacons.closed...)
+	for _, v := range s.beacons.active {
+		o.Timeline = append(o.Timeline, *v)
+	}
+	s.lock.Unlock()
+	sort.Slice(o.Timeline, func(i, j int) bool {
+		if o.Timeline[i].First == o.Timeline[j].First {
+			return o.Timeline[i].ID < o.Timeline[j].ID
+		}
+		return o.Timeline[i].First < o.Timeline[j].First
+	})
+	a, d := make(map[uint64]*dwell), make(map[uint64]*dwell)
+	for _, v := range o.Timeline {
+		if _, ok := d[v.Target]; !ok {
+			d[v.Target] = &dwell{Team: v.Target, Name: x[v.Target]}
+		}
+		if _, ok := a[v.Attacker]; !ok {
+			a[v.Attacker] = &dwell{Team: v.Attacker, Name: x[v.Attacker]}
+		}
+		d[v.Target].add(v, n)
+		a[v.Attacker].add(v, n)
+	}
+	o.Targets, o.Attackers = dwells(d), dwells(a)
+	return o, true
+}
+func dwells(m map[uint64]*dwell) []dwell {
+	o := make([]dwell, 0, len(m))
+	for _, v := range m {
+		o = append(o, *v)
+	}
+	sort.Slice(o, func(i, j int) bool { return o[i].Team < o[j].Team })
+	return o
+}
@@ -0,0 +1,154 @@
+// Copyright(C) 2020 - 2023 iDigitalFlame
+//
+// This program is free software: you can redistribute it and / or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, either version 3 of the License, or
+// any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.If not, see <https://www.gnu.org/licenses/>.
+//
+
+package game
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	byTotal  metric = 0x0
+	byHealth metric = 0x1
+	byFlags  metric = 0x2
+)
+
+type metric uint8
+
+// ranking is the ranking configuration for all the Games in a Manager.
+type ranking struct {
+	metric metric
+	order  bool
+}
+
+func (t *team) value(m metric) int64 {
+	switch m {
+	case byHealth:
+		return t.Score.Health
+	case byFlags:
+		return int64(t.Flags.Captured) - int64(t.Flags.Lost)
+	}
+	return t.Score.Total
+}
+
+// rank sets the rank, the last rank change and the lead margin of every defending Team.
+// The rank change is kept until the rank changes again, so it does not go back to
+// unchanged on the next Game state. Offense Teams are not ranked. Teams with the same metric value
+// are ordered by total score and then by ID, so the ranking is always deterministic.
+//
+// The lead margin of the first Team is how far ahead of the second Team it is, for every
+// other Team it's how far behind the first Team it is (as a negative value).
+func (g *game) rank(r ranking, old *game) {
+	l := make([]*team, 0, len(g.Teams))
+	for i := range g.Teams {
+		if g.Teams[i].Offense {
+			continue
+		}
+		l = append(l, &g.Teams[i])
+	}
+	sort.Slice(l, func(i, j int) bool {
+		if a, b := l[i].value(r.metric), l[j].value(r.metric); a != b {
+			return a > b
+		}
+		if l[i].Score.Total != l[j].Score.Total {
+			return l[i].Score.Total > l[j].Score.Total
+		}
+		return l[i].ID < l[j].ID
+	})
+	var p map[uint64]*team
+	if old != nil && len(old.Teams) > 0 {
+		p = make(map[uint64]*team, len(old.Teams))
+		for i := range old.Teams {
+			p[old.Teams[i].ID] = &old.Teams[i]
+		}
+	}
+	for i, t := range l {
+		t.rank, t.order = i+1, r.order
+		switch v, ok := p[t.ID]; {
+		case !ok || v.rank == 0:
+		case v.rank != t.rank:
+			t.move = v.rank - t.rank
+		default:
+			t.move = v.move
+		}
+		switch {
+		case i > 0:
+			t.lead = t.value(r.metric) - l[0].value(r.metric)
+		case len(l) > 1:
+			t.lead = t.value(r.metric) - l[1].value(r.metric)
+		}
+	}
+}
+func (t team) compareRank(p *planner, o team) {
+	if t.rank == 0 {
+		// NOTE(dij): Teams that switch to offense lose their rank.
+		if o.rank > 0 {
+			p.Remove("name-rank")
+			p.Remove("name-rmove")
+			p.Remove("name-lead")
+		}
+		return
+	}
+	var m, c string
+	switch {
+	case t.move > 0:
+		m, c = "+"+strconv.Itoa(t.move), "team-rank-move up"
+	case t.move < 0:
+		m, c = strconv.Itoa(t.move), "team-rank-move down"
+	default:
+		m, c = "=", "team-rank-move same"
+	}
+	l := strconv.FormatInt(t.lead, 10)
+	if t.lead > 0 {
+		l = "+" + l
+	}
+	if o.ID != 0 && o.rank == t.rank && o.move == t.move && o.lead == t.lead {
+		p.Value("name-rank", t.rank, "team-rank")
+		p.Value("name-rmove", m, c)
+		p.Value("name-lead", l, "team-lead")
+		if t.order {
+			p.Property("", t.rank, "order")
+		}
+		return
+	}
+	p.DeltaValue("name-rank", t.rank, "team-rank")
+	p.DeltaValue("name-rmove", m, c)
+	p.DeltaValue("name-lead", l, "team-lead")
+	if t.order {
+		p.DeltaProperty("", t.rank, "order")
+	}
+}
+
+// SetRanking sets the metric used to rank the Teams in each Game, which can be "total"
+// (the default), "health" or "flags" (captured minus lost). If order is true, the Teams
+// are displayed in rank order instead of by ID. This function must be called before 'Start'.
+func (m *Manager) SetRanking(v string, order bool) error {
+	switch strings.ToLower(v) {
+	case "total", "":
+		m.ranking.metric = byTotal
+	case "health":
+		m.ranking.metric = byHealth
+	case "flags":
+		m.ranking.metric = byFlags
+	default:
+		return errors.New(`invalid ranking metric "` + v + `"`)
+	}
+	m.ranking.order = order
+	return nil
+}
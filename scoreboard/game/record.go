@@ -0,0 +1,163 @@
+// Copyright(C) 2020 - 2023 iDigitalFlame
+//
+// This program is free software: you can redistribute it and / or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, either version 3 of the License, or
+// any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.If not, see <https://www.gnu.org/licenses/>.
+//
+
+package game
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// capture is a single line in a capture file. Each line is either a Scorebot API
+// response body, keyed by the API path it was requested from, or a Tweet.
+//
+// Every successful request is recorded. Responses that did not change have no data and
+// repeat the last data for the same path. Tick is the poll interval (in milliseconds)
+// of the recording Manager, which is used to tell when requests have failed.
+type capture struct {
+	Tweet *tweet          `json:"tweet,omitempty"`
+	Path  string          `json:"path,omitempty"`
+	Data  json.RawMessage `json:"data,omitempty"`
+	Time  int64           `json:"time"`
+	Tick  int64           `json:"tick,omitempty"`
+}
+type counter struct {
+	*os.File
+	n int64
+}
+type recorder struct {
+	f     *counter
+	w     *gzip.Writer
+	lock  sync.Mutex
+	path  string
+	limit int64
+}
+
+func (r *recorder) close() {
+	if r == nil {
+		return
+	}
+	r.lock.Lock()
+	r.closeFile()
+	r.lock.Unlock()
+}
+func (r *recorder) closeFile() {
+	if r.f == nil {
+		return
+	}
+	r.w.Close()
+	r.f.Close()
+	r.f, r.w = nil, nil
+}
+func (c *counter) Write(b []byte) (int, error) {
+	n, err := c.File.Write(b)
+	c.n += int64(n)
+	return n, err
+}
+
+// rotate closes the current capture file (if any) and opens a new one. Capture files
+// are named after the configured path with the time they were started inserted before
+// the extension, so sorting the names puts them in order.
+func (r *recorder) rotate() error {
+	r.closeFile()
+	var (
+		d, b = filepath.Split(r.path)
+		e    string
+	)
+	if i := strings.IndexByte(b, '.'); i > 0 {
+		b, e = b[:i], b[i:]
+	}
+	f, err := os.OpenFile(
+		filepath.Join(d, b+"-"+time.Now().UTC().Format("20060102T150405.000")+e),
+		os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0640,
+	)
+	if err != nil {
+		return err
+	}
+	r.f = &counter{File: f}
+	r.w = gzip.NewWriter(r.f)
+	return nil
+}
+func (r *recorder) write(c capture) error {
+	if r == nil {
+		return nil
+	}
+	b, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.f == nil || (r.limit > 0 && r.f.n >= r.limit) {
+		if err = r.rotate(); err != nil {
+			return err
+		}
+	}
+	if _, err = r.w.Write(append(b, '\n')); err != nil {
+		return err
+	}
+	// Flush each line so a crash or kill will only lose the last entry.
+	return r.w.Flush()
+}
+
+// record writes the response to the capture file. An empty response is recorded as an
+// unchanged response.
+func (m *Manager) record(p string, b []byte) {
+	if m.recorder == nil {
+		return
+	}
+	if len(b) > 0 && !json.Valid(b) {
+		m.log.Warning(`Not recording invalid JSON response from "%s"!`, p)
+		return
+	}
+	if err := m.recorder.write(capture{Path: p, Data: b, Time: time.Now().UnixMilli(), Tick: m.interval.Milliseconds()}); err != nil {
+		m.log.Error(`Could not write capture for "%s": %s!`, p, err.Error())
+	}
+}
+func (m *Manager) recordTweet(t tweet) {
+	if m.recorder == nil {
+		return
+	}
+	if err := m.recorder.write(capture{Tweet: &t, Time: time.Now().UnixMilli()}); err != nil {
+		m.log.Error("Could not write capture for Tweet %d: %s!", t.ID, err.Error())
+	}
+}
+
+// SetRecord enables recording of every successful Scorebot response and Tweet to gzip
+// compressed JSON-lines capture files. Unchanged responses are recorded without their
+// body, so replays know when each request was made. A new file is started once the
+// current one reaches the size limit (in bytes), a limit of zero disables rotation. This
+// function must be called before 'Start'.
+func (m *Manager) SetRecord(p string, limit int64) error {
+	if len(p) == 0 {
+		return errors.New("capture path cannot be empty")
+	}
+	r := &recorder{path: p, limit: limit}
+	r.lock.Lock()
+	err := r.rotate()
+	r.lock.Unlock()
+	if err != nil {
+		return err
+	}
+	m.recorder = r
+	return nil
+}
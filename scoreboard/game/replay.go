@@ -0,0 +1,279 @@
+// Copyright(C) 2020 - 2023 iDigitalFlame
+//
+// This program is free software: you can redistribute it and / or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, either version 3 of the License, or
+// any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.If not, see <https://www.gnu.org/licenses/>.
+//
+
+package game
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/dghubble/go-twitter/twitter"
+)
+
+var errNoCapture = errors.New("no capture data for the requested path")
+
+// replay is a data source that serves Scorebot responses from capture files instead
+// of Scorebot. It keeps a virtual clock in capture time that advances along with the
+// wall clock (multiplied by the speed) unless paused.
+type replay struct {
+	base    time.Time
+	paths   map[string][]*capture
+	tweets  []*capture
+	lock    sync.Mutex
+	speed   float64
+	start   int64
+	end     int64
+	pos     int64
+	last    int64
+	paused  bool
+	rewound bool
+}
+
+// ReplayState is a snapshot of the replay clock. All times are in capture time.
+type ReplayState struct {
+	Time   time.Time `json:"time"`
+	Start  time.Time `json:"start"`
+	End    time.Time `json:"end"`
+	Speed  float64   `json:"speed"`
+	Paused bool      `json:"paused"`
+}
+
+// now returns the current capture time. The caller must hold the replay lock.
+func (r *replay) now() int64 {
+	if r.paused {
+		return r.pos
+	}
+	v := r.pos + int64(float64(time.Since(r.base).Milliseconds())*r.speed)
+	if v > r.end {
+		return r.end
+	}
+	return v
+}
+func readCapture(n string, r *replay) error {
+	f, err := os.Open(n)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	z, err := gzip.NewReader(f)
+	if err != nil {
+		return err
+	}
+	defer z.Close()
+	for b := bufio.NewReaderSize(z, 1<<16); ; {
+		l, err := b.ReadBytes('\n')
+		if l = bytes.TrimSpace(l); len(l) > 0 {
+			var c capture
+			if json.Unmarshal(l, &c) == nil {
+				if c.Tweet != nil {
+					r.tweets = append(r.tweets, &c)
+				} else if len(c.Path) > 0 {
+					r.paths[c.Path] = append(r.paths[c.Path], &c)
+				}
+				if r.start == 0 || c.Time < r.start {
+					r.start = c.Time
+				}
+				if c.Time > r.end {
+					r.end = c.Time
+				}
+			}
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			// An unexpected EOF is a capture that was cut off (crash or kill), use
+			// everything we got up to that point.
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+func (r *replay) state() ReplayState {
+	r.lock.Lock()
+	s := ReplayState{
+		Time:   time.UnixMilli(r.now()),
+		Start:  time.UnixMilli(r.start),
+		End:    time.UnixMilli(r.end),
+		Speed:  r.speed,
+		Paused: r.paused,
+	}
+	r.lock.Unlock()
+	return s
+}
+
+// get returns the newest captured response for the path that is not after the
+// current capture time.
+func (r *replay) get(p string) ([]byte, uint64, error) {
+	r.lock.Lock()
+	var (
+		n    = r.now()
+		l, g = r.paths[p], r.paths["api/games"]
+		i, x = before(l, n), before(g, n)
+	)
+	r.lock.Unlock()
+	if i == 0 {
+		return nil, 0, errNoCapture
+	}
+	// NOTE(dij): Every successful request is recorded and the Games list is requested
+	//            on every tick, so a gap of more than two ticks in it means Scorebot
+	//            could not be reached at this point.
+	if x > 0 && g[x-1].Tick > 0 && n-g[x-1].Time > g[x-1].Tick*2 {
+		return nil, 0, errOffline
+	}
+	h := updateFnv(fnvStart, l[i-1].Data)
+	if h == 0 {
+		h = 1
+	}
+	return l[i-1].Data, h, nil
+}
+
+// before returns the number of captures that are not after the supplied time.
+func before(l []*capture, n int64) int {
+	return sort.Search(len(l), func(i int) bool { return l[i].Time > n })
+}
+
+// pending returns the Tweets captured since the last call and true if the clock was
+// moved backwards since then, meaning any current Tweets should be dropped.
+func (r *replay) pending() ([]tweet, bool) {
+	r.lock.Lock()
+	var (
+		n = r.now()
+		i = sort.Search(len(r.tweets), func(i int) bool { return r.tweets[i].Time > r.last })
+		w = r.rewound
+		o []tweet
+	)
+	for ; i < len(r.tweets) && r.tweets[i].Time <= n; i++ {
+		o = append(o, *r.tweets[i].Tweet)
+	}
+	r.last, r.rewound = n, false
+	r.lock.Unlock()
+	return o, w
+}
+
+// ReplayPause pauses or resumes the replay clock. This function does nothing if the
+// Manager is not in replay mode.
+func (m *Manager) ReplayPause(p bool) {
+	if m.replay == nil {
+		return
+	}
+	m.replay.lock.Lock()
+	if p != m.replay.paused {
+		m.replay.pos, m.replay.base, m.replay.paused = m.replay.now(), time.Now(), p
+	}
+	m.replay.lock.Unlock()
+}
+
+// ReplaySpeed changes the replay clock speed multiplier. Values less than or equal
+// to zero are ignored. This function does nothing if the Manager is not in replay mode.
+func (m *Manager) ReplaySpeed(v float64) {
+	if m.replay == nil || v <= 0 {
+		return
+	}
+	m.replay.lock.Lock()
+	m.replay.pos, m.replay.base, m.replay.speed = m.replay.now(), time.Now(), v
+	m.replay.lock.Unlock()
+}
+
+// ReplaySeek moves the replay clock to the supplied capture time, which is limited to
+// the start and end of the capture. This function does nothing if the Manager is not in
+// replay mode.
+func (m *Manager) ReplaySeek(t time.Time) {
+	if m.replay == nil {
+		return
+	}
+	v := t.UnixMilli()
+	m.replay.lock.Lock()
+	if v < m.replay.start {
+		v = m.replay.start
+	}
+	if v > m.replay.end {
+		v = m.replay.end
+	}
+	// NOTE(dij): Tweets in the skipped range are not shown, which prevents a
+	//            large forward jump from flooding the board with Tweets.
+	m.replay.rewound = m.replay.rewound || v < m.replay.now()
+	m.replay.pos, m.replay.base, m.replay.last = v, time.Now(), v
+	m.replay.lock.Unlock()
+}
+
+// ReplayState returns the state of the replay clock and true if the Manager is in
+// replay mode.
+func (m *Manager) ReplayState() (ReplayState, bool) {
+	if m.replay == nil {
+		return ReplayState{}, false
+	}
+	return m.replay.state(), true
+}
+
+// SetReplay loads the capture files matching the supplied glob pattern and switches
+// the Manager to serve data from them instead of Scorebot. The replay starts at the
+// beginning of the capture and advances at the supplied speed. Tweets in the capture
+// are shown for the supplied expire duration. This function must be called before 'Start'.
+func (m *Manager) SetReplay(p string, speed float64, expire time.Duration) error {
+	l, err := filepath.Glob(p)
+	if err != nil {
+		return err
+	}
+	if len(l) == 0 {
+		return errors.New(`no capture files match "` + p + `"`)
+	}
+	if speed <= 0 {
+		speed = 1
+	}
+	sort.Strings(l)
+	r := &replay{paths: make(map[string][]*capture), speed: speed}
+	for i := range l {
+		if err = readCapture(l[i], r); err != nil {
+			return errors.New(`unable to read capture "` + l[i] + `": ` + err.Error())
+		}
+	}
+	if r.start == 0 {
+		return errors.New(`capture files "` + p + `" are empty`)
+	}
+	for k, v := range r.paths {
+		sort.SliceStable(v, func(i, j int) bool { return v[i].Time < v[j].Time })
+		// NOTE(dij): Unchanged responses repeat the last data for the path, any
+		//            without data before them cannot be served and are dropped.
+		x := v[:0]
+		for _, c := range v {
+			if len(c.Data) == 0 {
+				if len(x) == 0 {
+					continue
+				}
+				c.Data = x[len(x)-1].Data
+			}
+			x = append(x, c)
+		}
+		r.paths[k] = x
+	}
+	sort.SliceStable(r.tweets, func(i, j int) bool { return r.tweets[i].Time < r.tweets[j].Time })
+	r.pos, r.last, r.base = r.start, r.start-1, time.Now()
+	m.replay = r
+	if m.twitter == nil && len(r.tweets) > 0 {
+		m.twitter = &tweets{new: make(chan *twitter.Tweet), timeout: expire}
+	}
+	m.log.Info("Loaded %d capture files for replay (%d paths, %d Tweets).", len(l), len(r.paths), len(r.tweets))
+	return nil
+}
@@ -17,76 +17,24 @@
 package game
 
 type score struct {
-	Total  int64 `json:"total"`
-	Health int64 `json:"health"`
+	Total  int64 `json:"total" sb:"value,name-total,score-total score"`
+	Health int64 `json:"health" sb:"value,score-health,score-health score"`
 
+	pos  uint64
 	hash uint64
 }
 type scoreFlag struct {
-	Open     uint32 `json:"open"`
-	Lost     uint32 `json:"lost"`
-	Captured uint32 `json:"captured"`
+	Open     uint32 `json:"open" sb:"value,score-fopen,score-flag-open score score-flag"`
+	Lost     uint32 `json:"lost" sb:"value,score-flost,score-flag-lost score score-flag"`
+	Captured uint32 `json:"captured" sb:"value,score-fcaptured,score-flag-captured score score-flag"`
 
+	pos  uint64
 	hash uint64
 }
 type scoreTicket struct {
-	Open   uint32 `json:"open"`
-	Closed uint32 `json:"closed"`
+	Open   uint32 `json:"open" sb:"value,score-topen,score-ticket-open score score-ticket"`
+	Closed uint32 `json:"closed" sb:"value,score-tclosed,score-ticket-closed score score-ticket"`
 
+	pos  uint64
 	hash uint64
 }
-
-func (s *score) Hash(h *hasher) uint64 {
-	if s.hash == 0 {
-		h.Hash(s.Total)
-		h.Hash(s.Health)
-		s.hash = h.Segment()
-	}
-	return s.hash
-}
-func (s *scoreFlag) Hash(h *hasher) uint64 {
-	if s.hash == 0 {
-		h.Hash(s.Open)
-		h.Hash(s.Lost)
-		h.Hash(s.Captured)
-		s.hash = h.Segment()
-	}
-	return s.hash
-}
-func (s score) Compare(p *planner, o score) {
-	if o.hash == s.hash {
-		p.Value("name-total", s.Total, "score-total score")
-		p.Value("score-health", s.Health, "score-health score")
-		return
-	}
-	p.DeltaValue("name-total", s.Total, "score-total score")
-	p.DeltaValue("score-health", s.Health, "score-health score")
-}
-func (s *scoreTicket) Hash(h *hasher) uint64 {
-	if s.hash == 0 {
-		h.Hash(s.Open)
-		h.Hash(s.Closed)
-		s.hash = h.Segment()
-	}
-	return s.hash
-}
-func (s scoreFlag) Compare(p *planner, o scoreFlag) {
-	if o.hash == s.hash {
-		p.Value("score-fopen", s.Open, "score-flag-open score score-flag")
-		p.Value("score-flost", s.Lost, "score-flag-lost score score-flag")
-		p.Value("score-fcaptured", s.Captured, "score-flag-captured score score-flag")
-		return
-	}
-	p.DeltaValue("score-fpen", s.Open, "score-flag-open score score-flag")
-	p.DeltaValue("score-flost", s.Lost, "score-flag-lost score score-flag")
-	p.DeltaValue("score-fcaptured", s.Captured, "score-flag-captured score score-flag")
-}
-func (s scoreTicket) Compare(p *planner, o scoreTicket) {
-	if o.hash == s.hash {
-		p.Value("score-topen", s.Open, "score-ticket-open score score-ticket")
-		p.Value("score-tclosed", s.Closed, "score-ticket-closed score score-ticket")
-		return
-	}
-	p.DeltaValue("score-topen", s.Open, "score-ticket-open score score-ticket")
-	p.DeltaValue("score-tclosed", s.Closed, "score-ticket-closed score score-ticket")
-}
@@ -0,0 +1,146 @@
+// Copyright(C) 2020 - 2023 iDigitalFlame
+//
+// This program is free software: you can redistribute it and / or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, either version 3 of the License, or
+// any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.If not, see <https://www.gnu.org/licenses/>.
+//
+
+package game
+
+import (
+	"sort"
+	"time"
+)
+
+// point is a single sample of the scores of a Team.
+type point struct {
+	Time          int64  `json:"time"`
+	Total         int64  `json:"total"`
+	Health        int64  `json:"health"`
+	FlagsOpen     uint32 `json:"flags_open"`
+	FlagsLost     uint32 `json:"flags_lost"`
+	FlagsCaptured uint32 `json:"flags_captured"`
+	TicketsOpen   uint32 `json:"tickets_open"`
+	TicketsClosed uint32 `json:"tickets_closed"`
+}
+
+// sample is a point for a Team that is sent to the clients as it is taken. A sample
+// with the same time as the last point for the Team replaces it.
+type sample struct {
+	point
+	Team uint64 `json:"team"`
+}
+type series struct {
+	Teams    []seriesTeam `json:"teams"`
+	Game     uint64       `json:"game"`
+	Interval int64        `json:"interval"`
+}
+type seriesTeam struct {
+	Name   string  `json:"name"`
+	Color  string  `json:"color"`
+	Points []point `json:"points"`
+	ID     uint64  `json:"id"`
+}
+
+func (p point) same(o point) bool {
+	o.Time = p.Time
+	return p == o
+}
+
+// sample adds a point for each Team from the last Game state at the supplied time.
+// Points are taken at most once per interval, a newer sample in the same interval
+// replaces the last point instead. Points older than the retention time are removed.
+// The caller must hold the subscription lock.
+func (s *subscription) sample(m *Manager, t time.Time) {
+	if m.sampling <= 0 {
+		return
+	}
+	if s.points == nil {
+		s.points = make(map[uint64][]point, len(s.last.Teams))
+	}
+	var (
+		v = t.UnixMilli() / m.sampling.Milliseconds() * m.sampling.Milliseconds()
+		c = t.Add(-m.retention).UnixMilli()
+	)
+	for i := range s.last.Teams {
+		var (
+			x = &s.last.Teams[i]
+			p = point{
+				Time:          v,
+				Total:         x.Score.Total,
+				Health:        x.Score.Health,
+				FlagsOpen:     x.Flags.Open,
+				FlagsLost:     x.Flags.Lost,
+				FlagsCaptured: x.Flags.Captured,
+				TicketsOpen:   x.Tickets.Open,
+				TicketsClosed: x.Tickets.Closed,
+			}
+			l = s.points[x.ID]
+		)
+		switch n := len(l); {
+		case n > 0 && l[n-1].Time == v:
+			if l[n-1].same(p) {
+				continue
+			}
+			l[n-1] = p
+		case n > 0 && l[n-1].Time > v:
+			// NOTE(dij): Older data (from a racing push or poll), skip it.
+			continue
+		default:
+			l = append(l, p)
+		}
+		if m.retention > 0 {
+			k := sort.Search(len(l), func(i int) bool { return l[i].Time >= c })
+			if k == len(l) {
+				k--
+			}
+			l = l[k:]
+		}
+		s.points[x.ID] = l
+		s.pending = append(s.pending, sample{Team: x.ID, point: p})
+	}
+}
+
+// History returns the score history of every Team in the subscribed Game and true if
+// the Game is currently subscribed. History is only kept for Games that clients are
+// watching.
+func (m *Manager) History(i uint64) (series, bool) {
+	m.lock.RLock()
+	s := m.subs[i]
+	m.lock.RUnlock()
+	if s == nil || m.sampling <= 0 {
+		return series{}, false
+	}
+	s.lock.Lock()
+	o := series{Game: i, Interval: m.sampling.Milliseconds(), Teams: make([]seriesTeam, 0, len(s.last.Teams))}
+	for _, t := range s.last.Teams {
+		o.Teams = append(o.Teams, seriesTeam{
+			ID:     t.ID,
+			Name:   t.Name,
+			Color:  t.Color,
+			Points: append([]point(nil), s.points[t.ID]...),
+		})
+	}
+	s.lock.Unlock()
+	return o, true
+}
+
+// SetHistory sets the interval between score history points and how long they are kept
+// for. An interval of zero or less disables the history, a retention of zero or less
+// keeps the points as long as the Game is subscribed. This function must be called
+// before 'Start'.
+func (m *Manager) SetHistory(interval, retention time.Duration) {
+	if interval < time.Millisecond {
+		interval = 0
+	}
+	m.sampling, m.retention = interval, retention
+}
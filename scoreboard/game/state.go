@@ -0,0 +1,143 @@
+// Copyright(C) 2020 - 2023 iDigitalFlame
+//
+// This program is free software: you can redistribute it and / or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, either version 3 of the License, or
+// any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.If not, see <https://www.gnu.org/licenses/>.
+//
+
+package game
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const stateGames = "games.json"
+
+// saved is a Game loaded from the state directory.
+type saved struct {
+	time time.Time
+	body []byte
+}
+
+func stateGame(i uint64) string {
+	return "game-" + strconv.FormatUint(i, 10) + ".json"
+}
+
+// persist writes the data to the named file in the state directory. The file is
+// written to a temporary file first and renamed, so a crash will never leave a
+// partially written state file.
+func (m *Manager) persist(n string, b []byte) {
+	if len(m.state) == 0 {
+		return
+	}
+	f, err := os.CreateTemp(m.state, n+".*")
+	if err != nil {
+		m.log.Error(`Could not save state file "%s": %s!`, n, err.Error())
+		return
+	}
+	_, err = f.Write(b)
+	if c := f.Close(); err == nil {
+		err = c
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), filepath.Join(m.state, n))
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		m.log.Error(`Could not save state file "%s": %s!`, n, err.Error())
+	}
+}
+func (m *Manager) persistGames(g []meta) {
+	if len(m.state) == 0 {
+		return
+	}
+	b, err := json.Marshal(g)
+	if err != nil {
+		m.log.Error("Could not encode Games list state: %s!", err.Error())
+		return
+	}
+	m.persist(stateGames, b)
+}
+
+// restore returns the saved Game data for the supplied ID and true if it exists.
+func (m *Manager) restore(i uint64) (saved, bool) {
+	if len(m.state) == 0 {
+		return saved{}, false
+	}
+	v, err := m.load(stateGame(i))
+	if err != nil {
+		if !os.IsNotExist(err) {
+			m.log.Warning(`Skipping unreadable state file "%s": %s!`, stateGame(i), err.Error())
+		}
+		return saved{}, false
+	}
+	return v, true
+}
+
+// SetState sets the directory used to save the last known Games list and Game data.
+// The saved Games list is loaded and saved Game data is served right away (marked as
+// stale) when a client requests a Game that is not loaded yet, then refreshed from
+// Scorebot in the background. This way clients are not kept waiting if Scorebot cannot
+// be reached. This function must be called before 'Start'.
+func (m *Manager) SetState(d string) error {
+	if len(d) == 0 {
+		return errors.New("state directory cannot be empty")
+	}
+	if err := os.MkdirAll(d, 0750); err != nil {
+		return err
+	}
+	m.state = d
+	v, err := m.load(stateGames)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			m.log.Warning(`Skipping unreadable state file "%s": %s!`, stateGames, err.Error())
+		}
+		return nil
+	}
+	var g []meta
+	if err = json.Unmarshal(v.body, &g); err != nil {
+		m.log.Warning(`Skipping unreadable state file "%s": %s!`, stateGames, err.Error())
+		return nil
+	}
+	a := make(map[string]uint64, len(g))
+	for i := range g {
+		if g[i].Active() {
+			a[strings.ToLower(cleanSlugString(g[i].Name))] = g[i].ID
+		}
+	}
+	// NOTE(dij): The Games sum is left at zero, so the first successful poll will
+	//            always replace the saved list.
+	m.games, m.active = g, a
+	m.log.Info(`Loaded saved Games list (%d Games) from "%s".`, len(g), d)
+	return nil
+}
+func (m *Manager) load(n string) (saved, error) {
+	f := filepath.Join(m.state, n)
+	i, err := os.Stat(f)
+	if err != nil {
+		return saved{}, err
+	}
+	b, err := os.ReadFile(f)
+	if err != nil {
+		return saved{}, err
+	}
+	if !json.Valid(b) {
+		return saved{}, errors.New("invalid JSON")
+	}
+	return saved{time: i.ModTime(), body: b}, nil
+}
@@ -0,0 +1,103 @@
+// Copyright(C) 2020 - 2023 iDigitalFlame
+//
+// This program is free software: you can redistribute it and / or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, either version 3 of the License, or
+// any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.If not, see <https://www.gnu.org/licenses/>.
+//
+
+package game
+
+import (
+	"encoding/json"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// streamQueue is the max number of pending messages a client may have before
+// it is considered a slow consumer. Slow consumers are disconnected, they will
+// reconnect and resume from the subscription backlog (or a full snapshot).
+const streamQueue = 32
+
+type stream struct {
+	*websocket.Conn
+	out     chan *websocket.PreparedMessage
+	once    sync.Once
+	timeout time.Duration
+	dead    uint32
+	// offense is true if the client is on the offense view, which also receives the
+	// offense view updates.
+	offense bool
+}
+
+func (s *stream) kill() {
+	atomic.StoreUint32(&s.dead, 1)
+	s.once.Do(func() { s.Conn.Close() })
+}
+func (s *stream) stop() {
+	s.kill()
+	close(s.out)
+}
+func (s *stream) read() {
+	// NOTE(dij): We don't expect anything from the client after the Hello, but
+	//            we need to read to process control frames and notice when the
+	//            client goes away.
+	for {
+		if _, _, err := s.NextReader(); err != nil {
+			s.kill()
+			return
+		}
+	}
+}
+func (s *stream) write() {
+	for m := range s.out {
+		if atomic.LoadUint32(&s.dead) == 1 {
+			continue
+		}
+		s.SetWriteDeadline(time.Now().Add(s.timeout))
+		if err := s.WritePreparedMessage(m); err != nil {
+			s.kill()
+		}
+	}
+}
+func (s *stream) alive() bool {
+	return atomic.LoadUint32(&s.dead) == 0
+}
+
+// send queues the message to be written by the stream writer. This function
+// returns false if the client is dead or the queue is full.
+func (s *stream) send(m *websocket.PreparedMessage) bool {
+	if !s.alive() {
+		return false
+	}
+	select {
+	case s.out <- m:
+		return true
+	default:
+		return false
+	}
+}
+func prepare(v interface{}) (*websocket.PreparedMessage, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return websocket.NewPreparedMessage(websocket.TextMessage, b)
+}
+func newStream(c *websocket.Conn, t time.Duration) *stream {
+	s := &stream{Conn: c, out: make(chan *websocket.PreparedMessage, streamQueue), timeout: t}
+	go s.read()
+	go s.write()
+	return s
+}
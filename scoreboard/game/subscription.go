@@ -0,0 +1,356 @@
+// Copyright(C) 2020 - 2023 iDigitalFlame
+//
+// This program is free software: you can redistribute it and / or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, either version 3 of the License, or
+// any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.If not, see <https://www.gnu.org/licenses/>.
+//
+
+package game
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/PurpleSec/logx"
+	"github.com/gorilla/websocket"
+)
+
+// freshness is the longest time a subscription will go without sending anything
+// to its clients. If there are no changes, a message with only the freshness state
+// is sent instead.
+const freshness = time.Second * 15
+
+// subscription holds the state of a single Game that clients are watching. Everything
+// besides the ID is guarded by the subscription lock. Clients are added under the same
+// lock that updates are broadcast with, so a new client will never miss an update
+// between receiving its initial snapshot and joining.
+type subscription struct {
+	cache   []update
+	offense []update
+	clients []*stream
+	pending []sample
+	notices []notice
+	recent  []notice
+	points  map[uint64][]point
+	uptime  availability
+	beacons presence
+	rules   milestones
+	history backlog
+	last    game
+	fetched time.Time
+	sent    time.Time
+	lock    sync.Mutex
+	ID      uint64
+	sum     uint64
+	seq     uint64
+	epoch   uint64
+	stale   bool
+	done    bool
+	// restored is true when the Game data was loaded from the saved state instead
+	// of Scorebot, until the first successful poll.
+	restored bool
+}
+
+// join adds the client to the subscription and queues its initial message. This returns
+// false if the subscription was removed and the caller should try again.
+func (s *subscription) join(m *Manager, n *websocket.Conn, h hello) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.done {
+		return false
+	}
+	v, err := prepare(s.resume(m, h))
+	if err != nil {
+		m.log.Error(`Could not encode Game ID %d for "%s", closing: %s!`, h.Game, n.RemoteAddr().String(), err.Error())
+		n.Close()
+		return true
+	}
+	c := newStream(n, m.timeout)
+	c.offense = h.Offense
+	c.send(v)
+	s.clients, s.stale = append(s.clients, c), false
+	return true
+}
+
+// shutdown closes all the clients and marks the subscription as removed. The caller
+// must hold the subscription lock.
+func (s *subscription) shutdown() {
+	for i := range s.clients {
+		s.clients[i].stop()
+		s.clients[i] = nil
+	}
+	s.clients, s.done = nil, true
+}
+
+// resume returns the message that should be sent to a newly connected client. If the
+// client supplied the sequence number of the last update it received and the backlog
+// still covers it, only the missed updates are returned, otherwise the full snapshot is.
+// Offense view clients also receive the offense view updates.
+func (s *subscription) resume(m *Manager, h hello) message {
+	if h.Seq > 0 && h.Epoch == s.epoch && h.Seq <= s.seq {
+		if u, ok := s.history.since(h.Seq, h.Offense); ok || h.Seq == s.seq {
+			return s.message(m, u)
+		}
+	}
+	u := s.cache
+	if h.Offense {
+		u = append(s.cache[:len(s.cache):len(s.cache)], s.offense...)
+	}
+	v := s.message(m, u)
+	v.Full, v.Notices = true, s.recent
+	return v
+}
+
+// message returns a message with the supplied updates and the current sequence and
+// freshness state. The caller must hold the subscription lock.
+func (s *subscription) message(m *Manager, u []update) message {
+	return message{
+		Seq:     s.seq,
+		Tick:    m.interval.Milliseconds(),
+		Epoch:   s.epoch,
+		Server:  time.Now().UnixMilli(),
+		Fetched: s.fetched.UnixMilli(),
+		Stale:   s.restored,
+		Offline: m.breaker.state(),
+		Updates: u,
+	}
+}
+
+// outgoing returns a message with the supplied updates and any score history points and
+// activity items created since the last message was sent to the clients. The caller must
+// hold the subscription lock.
+func (s *subscription) outgoing(m *Manager, u []update) message {
+	// NOTE(dij): Only messages sent to all the clients reset the heartbeat timer, a
+	//            resume message only goes to the joining client.
+	v := s.message(m, u)
+	s.sent = time.Now()
+	v.Points, s.pending = s.pending, nil
+	v.Notices, s.notices = s.notices, nil
+	return v
+}
+
+// heartbeat sends the freshness state to the clients if nothing has been sent for a
+// while, so clients can tell the data is still current even if it has not changed.
+// The caller must hold the subscription lock.
+func (s *subscription) heartbeat(m *Manager) {
+	if time.Since(s.sent) < freshness {
+		return
+	}
+	v, err := prepare(s.outgoing(m, nil))
+	if err != nil {
+		m.log.Error("Could not encode heartbeat message for Game ID %d: %s!", s.ID, err.Error())
+		return
+	}
+	s.broadcast(m, v, v)
+}
+
+// control sends a message without any updates to all the clients, which is used to
+// signal a change in the Scorebot reachability state.
+func (s *subscription) control(m *Manager) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.done {
+		return
+	}
+	v, err := prepare(s.outgoing(m, nil))
+	if err != nil {
+		m.log.Error("Could not encode control message for Game ID %d: %s!", s.ID, err.Error())
+		return
+	}
+	s.broadcast(m, v, v)
+}
+func (s *subscription) update(x context.Context, m *Manager) {
+	defer func(l logx.Log) {
+		if err := recover(); err != nil {
+			l.Error("Game subscription update function recovered from a panic: %s!", err)
+		}
+	}(m.log)
+	m.log.Debug("Checking for update for subscribed Game %d..", s.ID)
+	var (
+		g game
+		t = time.Now()
+	)
+	b, h, err := m.get(x, "api/scoreboard/"+strconv.FormatUint(s.ID, 10))
+	if err == errOffline {
+		return
+	}
+	if err != nil {
+		m.log.Error("Error retrieving data for Game ID %d: %s!", s.ID, err.Error())
+		return
+	}
+	if s.unchanged(m, h, t) {
+		m.log.Trace("Game %d is unchanged, skipping comparison.", s.ID)
+		return
+	}
+	if err = json.Unmarshal(b, &g); err != nil {
+		m.log.Error("Error parsing data for Game ID %d: %s!", s.ID, err.Error())
+		return
+	}
+	m.persist(stateGame(s.ID), b)
+	select {
+	case <-x.Done():
+		return
+	default:
+	}
+	s.apply(m, g, t, h)
+}
+
+// unchanged returns true if the Scorebot data hash, Tweets and Game metadata are the same
+// as the last applied state, meaning a comparison would not produce any updates.
+func (s *subscription) unchanged(m *Manager, h uint64, t time.Time) bool {
+	var (
+		g game
+		w = m.twitter.get()
+	)
+	g.Meta.ID = s.ID
+	m.meta(&g)
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.done || h != s.sum || len(w) != len(s.last.Tweets) || s.rules.expired(t) {
+		return false
+	}
+	if g.Meta.Status != s.last.Meta.Status || !g.Meta.End.Equal(s.last.Meta.End) || !g.Meta.Start.Equal(s.last.Meta.Start) {
+		return false
+	}
+	for i := range w {
+		if w[i].ID != s.last.Tweets[i].ID {
+			return false
+		}
+	}
+	if t.After(s.fetched) {
+		s.fetched = t
+	}
+	s.fresh()
+	s.sample(m, t)
+	s.uptime.observe(&s.last, t, false)
+	s.beacons.observe(&s.last, t)
+	s.heartbeat(m)
+	return true
+}
+
+// apply compares the supplied Game data against the last known state and sends any
+// changes to the clients. The time is when the data was requested from Scorebot and is
+// used to ignore data older than what was already applied, which can happen when a
+// polled and pushed update race each other. The hash is of the raw Scorebot data, or
+// zero if unknown.
+func (s *subscription) apply(m *Manager, g game, t time.Time, h uint64) {
+	g.Meta.ID, g.Tweets, g.ranking = s.ID, m.twitter.get(), m.ranking
+	m.meta(&g)
+	g.announce = m.activity.get(g.Meta)
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.done || t.Before(s.fetched) {
+		return
+	}
+	s.fetched, s.sum = t, h
+	s.fresh()
+	var u, o []update
+	s.rules.check(m, &g, t)
+	s.uptime.observe(&g, t, m.callouts)
+	s.beacons.observe(&g, t)
+	m.log.Debug("Running game comparison on Game %d..", s.ID)
+	s.cache, u = g.Delta(m.assets, &s.last)
+	s.offense, o = g.Offense(&s.last)
+	s.ticker(g.notices, t.UnixMilli())
+	g.notices, s.last = nil, g
+	s.sample(m, t)
+	if len(u) == 0 && len(o) == 0 {
+		s.prune(m)
+		s.heartbeat(m)
+		return
+	}
+	m.log.Debug("%d Updates detected in Game %d, updating clients..", len(u), s.ID)
+	s.seq++
+	s.history.add(s.seq, u, o)
+	v := s.outgoing(m, u)
+	b, err := prepare(v)
+	if err != nil {
+		m.log.Error("Could not encode updates for Game ID %d: %s!", s.ID, err.Error())
+		return
+	}
+	x := b
+	if len(o) > 0 && s.viewing() {
+		v.Updates = append(u[:len(u):len(u)], o...)
+		if x, err = prepare(v); err != nil {
+			m.log.Error("Could not encode offense updates for Game ID %d: %s!", s.ID, err.Error())
+			return
+		}
+	}
+	s.broadcast(m, b, x)
+}
+
+// viewing returns true if any client is on the offense view. The caller must hold the
+// subscription lock.
+func (s *subscription) viewing() bool {
+	for i := range s.clients {
+		if s.clients[i].offense {
+			return true
+		}
+	}
+	return false
+}
+
+// fresh clears the restored flag, which also forces the next heartbeat to be sent
+// right away so the clients know the data is no longer stale. The caller must hold
+// the subscription lock.
+func (s *subscription) fresh() {
+	if s.restored {
+		s.restored, s.sent = false, time.Time{}
+	}
+}
+
+// broadcast queues the encoded message to every client without blocking, or the
+// offense message to the offense view clients. Any clients that are dead or cannot
+// keep up with the queue are dropped. The caller must hold the subscription lock.
+func (s *subscription) broadcast(m *Manager, v, o *websocket.PreparedMessage) {
+	r := s.clients[:0]
+	for i := range s.clients {
+		e := v
+		if s.clients[i].offense {
+			e = o
+		}
+		if !s.clients[i].send(e) {
+			if s.clients[i].alive() {
+				m.log.Warning(`Client "%s" is too slow for Game %d, disconnecting!`, s.clients[i].RemoteAddr().String(), s.ID)
+			} else {
+				m.log.Debug(`Client "%s" for Game %d went away, removing.`, s.clients[i].RemoteAddr().String(), s.ID)
+			}
+			s.clients[i].stop()
+			continue
+		}
+		r = append(r, s.clients[i])
+	}
+	for i := len(r); i < len(s.clients); i++ {
+		s.clients[i] = nil
+	}
+	s.clients = r
+}
+
+// prune removes any dead clients. The caller must hold the subscription lock.
+func (s *subscription) prune(m *Manager) {
+	r := s.clients[:0]
+	for i := range s.clients {
+		if !s.clients[i].alive() {
+			m.log.Debug(`Client "%s" for Game %d went away, removing.`, s.clients[i].RemoteAddr().String(), s.ID)
+			s.clients[i].stop()
+			continue
+		}
+		r = append(r, s.clients[i])
+	}
+	for i := len(r); i < len(s.clients); i++ {
+		s.clients[i] = nil
+	}
+	s.clients = r
+}
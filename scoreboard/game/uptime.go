@@ -0,0 +1,233 @@
+// Copyright(C) 2020 - 2023 iDigitalFlame
+//
+// This program is free software: you can redistribute it and / or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, either version 3 of the License, or
+// any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.If not, see <https://www.gnu.org/licenses/>.
+//
+
+package game
+
+import (
+	"math"
+	"strconv"
+	"time"
+)
+
+// uptime is the availability of a Host or Service. The Streak is only filled in for
+// the REST output, as it changes constantly. Clients can compute it from Since.
+type uptime struct {
+	Percent float64 `json:"uptime"`
+	Since   int64   `json:"since"`
+	Streak  int64   `json:"streak,omitempty"`
+	MTTR    int64   `json:"mttr"`
+	Outages int     `json:"outages"`
+	Up      bool    `json:"up"`
+	set     bool
+}
+
+// tracker follows the state of a single Host or Service across polls. Time between
+// polls is counted towards the state seen at the start of it.
+type tracker struct {
+	since   time.Time
+	last    time.Time
+	up      time.Duration
+	down    time.Duration
+	repair  time.Duration
+	fixed   int
+	outages int
+	state   bool
+}
+
+// availability holds the trackers for all the Hosts and Services in a Game. This is
+// guarded by the subscription lock.
+type availability struct {
+	hosts    map[uint64]*tracker
+	services map[uint64]*tracker
+}
+type uptimeGame struct {
+	Teams []uptimeTeam `json:"teams"`
+	Game  uint64       `json:"game"`
+}
+type uptimeHost struct {
+	uptime
+	Name     string          `json:"name"`
+	Services []uptimeService `json:"services"`
+	ID       uint64          `json:"id"`
+}
+type uptimeTeam struct {
+	Name  string       `json:"name"`
+	Hosts []uptimeHost `json:"hosts"`
+	ID    uint64       `json:"id"`
+}
+type uptimeService struct {
+	uptime
+	Protocol string `json:"protocol"`
+	ID       uint64 `json:"id"`
+	Port     uint16 `json:"port"`
+}
+
+func (k *tracker) observe(v bool, t time.Time) {
+	if k.last.IsZero() {
+		k.since, k.last, k.state = t, t, v
+		if !v {
+			k.outages++
+		}
+		return
+	}
+	if !t.After(k.last) {
+		return
+	}
+	if d := t.Sub(k.last); k.state {
+		k.up += d
+	} else {
+		k.down += d
+	}
+	if k.last = t; v == k.state {
+		return
+	}
+	if v {
+		k.fixed++
+		k.repair += t.Sub(k.since)
+	} else {
+		k.outages++
+	}
+	k.since, k.state = t, v
+}
+func (k *tracker) stats(t time.Time, streak bool) uptime {
+	u := uptime{Up: k.state, Since: k.since.UnixMilli(), Outages: k.outages, Percent: 100, set: true}
+	if a := k.up + k.down; a > 0 {
+		u.Percent = math.Round(float64(k.up)*1000/float64(a)) / 10
+	} else if !k.state {
+		u.Percent = 0
+	}
+	if k.fixed > 0 {
+		u.MTTR = int64((k.repair / time.Duration(k.fixed)).Seconds())
+	}
+	if streak {
+		u.Streak = int64(t.Sub(k.since).Seconds())
+	}
+	return u
+}
+func track(m map[uint64]*tracker, i uint64, v bool, t time.Time) *tracker {
+	k, ok := m[i]
+	if !ok {
+		k = new(tracker)
+		m[i] = k
+	}
+	k.observe(v, t)
+	return k
+}
+
+// observe records the state of every Host and Service in the Game at the supplied time
+// and removes the trackers of any that no longer exist. If mark is true, the current
+// stats are set on the Hosts and Services so they are sent to the clients. A Service is
+// only counted as up when its state is green.
+func (a *availability) observe(g *game, t time.Time, mark bool) {
+	if a.hosts == nil {
+		a.hosts, a.services = make(map[uint64]*tracker), make(map[uint64]*tracker)
+	}
+	h, s := make(map[uint64]struct{}, len(a.hosts)), make(map[uint64]struct{}, len(a.services))
+	for i := range g.Teams {
+		for x := range g.Teams[i].Hosts {
+			v := &g.Teams[i].Hosts[x]
+			k := track(a.hosts, v.ID, v.Online, t)
+			if h[v.ID] = struct{}{}; mark {
+				v.stat = k.stats(t, false)
+			}
+			for j := range v.Services {
+				e := &v.Services[j]
+				k := track(a.services, e.ID, e.State == green, t)
+				if s[e.ID] = struct{}{}; mark {
+					e.stat = k.stats(t, false)
+				}
+			}
+		}
+	}
+	for i := range a.hosts {
+		if _, ok := h[i]; !ok {
+			delete(a.hosts, i)
+		}
+	}
+	for i := range a.services {
+		if _, ok := s[i]; !ok {
+			delete(a.services, i)
+		}
+	}
+}
+
+// compareUptime adds the availability stats of a Host or Service for the callouts. The
+// Percent is sent with the single decimal place it is rounded to, and only when that
+// value changes. The MTTR and Since only change when the state does, so they are not
+// sent again while a Host or Service stays up or down.
+func compareUptime(p *planner, n, o uptime) {
+	if !n.set {
+		return
+	}
+	v := strconv.FormatFloat(n.Percent, 'f', 1, 64)
+	if o.set && o.Percent == n.Percent {
+		p.Property("", v, "data-uptime")
+	} else {
+		p.DeltaProperty("", v, "data-uptime")
+	}
+	if o.set && o.MTTR == n.MTTR && o.Since == n.Since {
+		p.Property("", n.MTTR, "data-mttr")
+		p.Property("", n.Since, "data-since")
+		return
+	}
+	p.DeltaProperty("", n.MTTR, "data-mttr")
+	p.DeltaProperty("", n.Since, "data-since")
+}
+
+// Uptime returns the availability of every Host and Service in the subscribed Game and
+// true if the Game is currently subscribed. Availability is only tracked for Games that
+// clients are watching.
+func (m *Manager) Uptime(i uint64) (uptimeGame, bool) {
+	m.lock.RLock()
+	s := m.subs[i]
+	m.lock.RUnlock()
+	if s == nil {
+		return uptimeGame{}, false
+	}
+	n := time.Now()
+	s.lock.Lock()
+	o := uptimeGame{Game: i, Teams: make([]uptimeTeam, 0, len(s.last.Teams))}
+	for _, t := range s.last.Teams {
+		if t.Offense {
+			continue
+		}
+		v := uptimeTeam{ID: t.ID, Name: t.Name, Hosts: make([]uptimeHost, 0, len(t.Hosts))}
+		for _, h := range t.Hosts {
+			x := uptimeHost{ID: h.ID, Name: h.Name, Services: make([]uptimeService, 0, len(h.Services))}
+			if k, ok := s.uptime.hosts[h.ID]; ok {
+				x.uptime = k.stats(n, true)
+			}
+			for _, e := range h.Services {
+				y := uptimeService{ID: e.ID, Port: e.Port, Protocol: e.Protocol.String()}
+				if k, ok := s.uptime.services[e.ID]; ok {
+					y.uptime = k.stats(n, true)
+				}
+				x.Services = append(x.Services, y)
+			}
+			v.Hosts = append(v.Hosts, x)
+		}
+		o.Teams = append(o.Teams, v)
+	}
+	s.lock.Unlock()
+	return o, true
+}
+
+// SetUptimeCallouts sets if the Host and Service availability stats are sent to the
+// clients, to be shown in the Host and Service callouts. This function must be called
+// before 'Start'.
+func (m *Manager) SetUptimeCallouts(e bool) {
+	m.callouts = e
+}
@@ -57,18 +57,36 @@ const interval_all = 15000;
 const interval_team = 7500;
 const interval_credit = 5000;
 
+// History Chart Constants
+const history_pad = 40;
+const history_grid = 4;
+const ticker_max = 25;
+
+// Reconnect Constants
+const reconnect_delay = 1000;
+const reconnect_max = 30000;
+
 function init() {
     document.sb_auto = false;
     document.sb_loaded = false;
     document.sb_callout = false;
     document.sb_tab_offset = null;
+    document.sb_seq = 0;
+    document.sb_epoch = 0;
+    document.sb_skew = 0;
+    document.sb_tick = 0;
+    document.sb_fetched = 0;
+    document.sb_history = null;
+    document.sb_retry = reconnect_delay;
     document.sb_debug = document.location.toString().indexOf("?debug") > 0;
+    document.sb_view = document.body.dataset.view || "board";
     debug("Starting init.. Selected Game id: " + game);
     if (!game) {
         debug("No game ID detected, bailing!");
         return;
     }
     window.addEventListener("resize", check_mobile);
+    window.addEventListener("resize", draw_history);
     document.sb_event = document.getElementById("event");
     document.sb_board = document.getElementById("board");
     document.sb_effect = document.getElementById("effect");
@@ -76,6 +94,12 @@ function init() {
     document.sb_event_data = document.getElementById("event-data");
     document.sb_event_title = document.getElementById("event-title");
     setInterval(scroll_elements, 200);
+    setInterval(update_fresh, 1000);
+    setInterval(update_beacon_ages, 1000);
+    connect();
+    debug("Init complete.");
+}
+function connect() {
     debug("Opening websocket..");
     let s = window.location.host + "/w";
     if (document.location.protocol.indexOf("https") >= 0) {
@@ -87,19 +111,22 @@ function init() {
     document.sb_socket.onopen = startup;
     document.sb_socket.onclose = closed;
     document.sb_socket.onmessage = recv;
-    debug("Init complete.");
 }
 function closed() {
     debug("Received websocket close signal.");
-    if (document.sb_loaded) {
-        display_close();
-    } else {
+    if (!document.sb_loaded) {
         display_invalid();
+        return;
     }
+    display_close();
+    debug("Reconnecting in " + document.sb_retry + "ms..");
+    setTimeout(connect, document.sb_retry);
+    document.sb_retry = Math.min(document.sb_retry * 2, reconnect_max);
 }
 function startup() {
     debug("Received websocket open signal.");
-    document.sb_socket.send(JSON.stringify({"game": game}));
+    let offense = document.sb_view === "offense" ? 1 : 0;
+    document.sb_socket.send(JSON.stringify({"game": game, "seq": document.sb_seq, "epoch": document.sb_epoch, "offense": offense}));
 }
 function exit_game() {
     alert(messages[Math.floor(Math.random() * messages.length)]);
@@ -167,8 +194,14 @@ function recv(message) {
         if (load_message !== null) {
             load_message.remove();
         }
+    } else {
+        display_open();
     }
     update_board(message.data);
+    if (!document.sb_loaded && document.sb_view === "offense") {
+        document.sb_loaded = true;
+        return;
+    }
     if (!document.sb_loaded) {
         if (is_mobile()) {
             navigate("overview")
@@ -181,6 +214,121 @@ function recv(message) {
             game_status.setAttribute("onclick", "return navigate('overview');");
         }
         document.sb_loaded = true;
+        load_history();
+    }
+}
+function load_history() {
+    fetch("/history/" + game).then(function(response) {
+        if (!response.ok) {
+            throw new Error("status " + response.status);
+        }
+        return response.json();
+    }).then(function(data) {
+        document.sb_history = {"interval": data.interval, "teams": {}};
+        for (let i = 0; i < data.teams.length; i++) {
+            document.sb_history.teams[data.teams[i].id] = data.teams[i];
+        }
+        debug("Loaded history for " + data.teams.length + " teams.");
+        draw_history();
+    }).catch(function(err) {
+        debug("Could not load history: " + err);
+        let history_tab = document.getElementById("history-tab");
+        if (history_tab !== null && document.sb_history === null) {
+            history_tab.remove();
+        }
+    });
+}
+function merge_history(points) {
+    if (document.sb_history === null) {
+        return;
+    }
+    for (let i = 0; i < points.length; i++) {
+        let team = document.sb_history.teams[points[i].team];
+        if (!team) {
+            team = {"id": points[i].team, "name": "Team " + points[i].team, "color": "#ffffff", "points": []};
+            document.sb_history.teams[points[i].team] = team;
+        }
+        let last = team.points.length - 1;
+        if (last >= 0 && team.points[last].time === points[i].time) {
+            team.points[last] = points[i];
+        } else {
+            team.points.push(points[i]);
+        }
+    }
+    draw_history();
+}
+function draw_history() {
+    let chart = document.getElementById("history-chart");
+    if (chart === null || document.sb_history === null || chart.offsetParent === null) {
+        return;
+    }
+    chart.width = chart.clientWidth;
+    chart.height = chart.clientHeight;
+    let ctx = chart.getContext("2d");
+    let teams = Object.values(document.sb_history.teams);
+    let start = Infinity, end = -Infinity, low = Infinity, high = -Infinity;
+    for (let i = 0; i < teams.length; i++) {
+        for (let x = 0; x < teams[i].points.length; x++) {
+            let point = teams[i].points[x];
+            start = Math.min(start, point.time);
+            end = Math.max(end, point.time);
+            low = Math.min(low, point.total);
+            high = Math.max(high, point.total);
+        }
+    }
+    ctx.clearRect(0, 0, chart.width, chart.height);
+    if (start === Infinity) {
+        return;
+    }
+    if (end === start) {
+        end = start + document.sb_history.interval;
+    }
+    if (high === low) {
+        high = low + 1;
+    }
+    let width = chart.width - history_pad * 2, height = chart.height - history_pad * 2;
+    ctx.font = "12px sans-serif";
+    ctx.fillStyle = "rgb(150, 150, 150)";
+    ctx.strokeStyle = "rgb(60, 60, 60)";
+    for (let i = 0; i <= history_grid; i++) {
+        let y = history_pad + height - (height * i / history_grid);
+        ctx.beginPath();
+        ctx.moveTo(history_pad, y);
+        ctx.lineTo(history_pad + width, y);
+        ctx.stroke();
+        ctx.fillText(Math.round(low + (high - low) * i / history_grid), 2, y + 4);
+        let when = new Date(start + (end - start) * i / history_grid);
+        ctx.fillText(when.toLocaleTimeString(), history_pad + (width * i / history_grid) - 25, chart.height - 10);
+    }
+    let legend = document.getElementById("history-legend");
+    if (legend !== null) {
+        legend.innerHTML = "";
+    }
+    for (let i = 0; i < teams.length; i++) {
+        if (teams[i].points.length === 0) {
+            continue;
+        }
+        ctx.lineWidth = 2;
+        ctx.strokeStyle = teams[i].color;
+        ctx.beginPath();
+        for (let x = 0; x < teams[i].points.length; x++) {
+            let point = teams[i].points[x];
+            let px = history_pad + width * (point.time - start) / (end - start);
+            let py = history_pad + height - height * (point.total - low) / (high - low);
+            if (x === 0) {
+                ctx.moveTo(px, py);
+            } else {
+                ctx.lineTo(px, py);
+            }
+        }
+        ctx.stroke();
+        ctx.lineWidth = 1;
+        if (legend !== null) {
+            let entry = document.createElement("span");
+            entry.innerText = teams[i].name;
+            entry.style.setProperty("--team-color", teams[i].color);
+            legend.appendChild(entry);
+        }
     }
 }
 function update_tabs() {
@@ -290,6 +438,35 @@ function navigate(panel) {
         }
     }
 }
+function display_open() {
+    document.sb_retry = reconnect_delay;
+    let disconnect_message = document.getElementById("game-disconnected");
+    if (disconnect_message !== null) {
+        disconnect_message.style.display = "none";
+    }
+}
+function server_time() {
+    return Date.now() + document.sb_skew;
+}
+function update_fresh() {
+    let fresh = document.getElementById("game-fresh");
+    if (fresh === null || document.sb_fetched <= 0) {
+        return;
+    }
+    let age = Math.max(0, Math.floor((server_time() - document.sb_fetched) / 1000));
+    fresh.innerText = "Updated " + age + "s ago";
+    if (document.sb_tick > 0 && age * 1000 > document.sb_tick * 3) {
+        fresh.classList.add("stale");
+    } else {
+        fresh.classList.remove("stale");
+    }
+}
+function display_delayed(offline) {
+    let delayed_message = document.getElementById("game-delayed");
+    if (delayed_message !== null) {
+        delayed_message.style.display = offline ? "block" : "none";
+    }
+}
 function display_close() {
     debug("Displaying closed board...");
     let disconnect_message = document.getElementById("game-disconnected");
@@ -302,6 +479,23 @@ function update_beacons() {
     for (let i = 0; i < beacons.length; i++) {
         set_beacon_image(beacons[i]);
     }
+    update_beacon_ages();
+}
+function update_beacon_ages() {
+    let beacons = document.getElementsByClassName("beacon");
+    for (let i = 0; i < beacons.length; i++) {
+        let since = parseInt(beacons[i].dataset.since);
+        if (!(since > 0)) {
+            continue;
+        }
+        let age = Math.max(0, Math.floor((server_time() - since) / 1000));
+        if (age < 3600) {
+            beacons[i].dataset.age = Math.floor(age / 60) + "m";
+        } else {
+            beacons[i].dataset.age = Math.floor(age / 3600) + "h";
+        }
+        beacons[i].title = "Active for " + format_duration(age);
+    }
 }
 function display_invalid() {
     debug("Displaying invalid board...");
@@ -333,9 +527,43 @@ function scroll_elements() {
     }
     */
 }
+function clear_board() {
+    let containers = ["game-team", "game-tweet", "game-offense"];
+    for (let i = 0; i < containers.length; i++) {
+        let container = document.getElementById(containers[i]);
+        if (container !== null) {
+            container.innerHTML = "";
+        }
+    }
+}
 function update_board(data) {
-    let updates = JSON.parse(data);
-    debug("Received " + updates.length + " entries...");
+    let message = JSON.parse(data);
+    if (message.full && document.sb_loaded) {
+        debug("Received full snapshot, clearing board..");
+        clear_board();
+        if (document.sb_view !== "offense") {
+            load_history();
+        }
+    }
+    document.sb_seq = message.seq;
+    document.sb_epoch = message.epoch;
+    display_delayed(message.offline || message.stale);
+    if (message.server) {
+        // Skew is added to the local clock to get the server time, this should be
+        // used for anything that needs to count time along with the server.
+        document.sb_skew = message.server - Date.now();
+        document.sb_tick = message.tick;
+        document.sb_fetched = message.fetched;
+        update_fresh();
+    }
+    if (message.points) {
+        merge_history(message.points);
+    }
+    if (message.full || message.notices) {
+        update_ticker(message.notices || [], message.full);
+    }
+    let updates = message.updates || [];
+    debug("Received " + updates.length + " entries (seq " + message.seq + ")...");
     for (let i = 0; i < updates.length; i++) {
         handle_update(updates[i]);
     }
@@ -355,6 +583,28 @@ function update_board(data) {
     callout_add("score-ticket-closed", "callout-ticket-closed");
     callout_add("score-flag-captured", "callout-flag-captured");
 }
+function update_ticker(notices, full) {
+    let ticker = document.getElementById("ticker");
+    let list = document.getElementById("ticker-list");
+    if (ticker === null || list === null) {
+        return;
+    }
+    if (full) {
+        list.innerHTML = "";
+    }
+    for (let i = 0; i < notices.length; i++) {
+        let item = document.createElement("span");
+        let time = new Date(notices[i].time);
+        item.classList.add("ticker-item");
+        item.classList.add("ticker-" + notices[i].kind);
+        item.innerText = ("0" + time.getHours()).slice(-2) + ":" + ("0" + time.getMinutes()).slice(-2) + " " + notices[i].text;
+        list.insertBefore(item, list.firstChild);
+    }
+    while (list.children.length > ticker_max) {
+        list.removeChild(list.lastChild);
+    }
+    ticker.style.display = list.children.length > 0 ? "block" : "none";
+}
 function scroll_element(ele) {
     if (ele.scrollWidth === 0) {
         ele.classList.remove("reverse");
@@ -398,8 +648,12 @@ function callout(event, type) {
     if (event.fromElement === null) {
         return;
     }
+    let uptime = callout_uptime(event.currentTarget);
     if (type === "callout-host" && !event.fromElement.classList.contains("offline")) {
-        return;
+        if (!uptime) {
+            return;
+        }
+        type = "callout-uptime";
     }
     let callout = document.getElementById("callout");
     if (callout === null) {
@@ -409,7 +663,7 @@ function callout(event, type) {
     callout.style.top = (event.clientY + 10) + "px";
     callout.style.left = (event.clientX + 10) + "px";
     for (let i = 0; i < callout.children.length; i++) {
-        if (callout.children[i].id === type) {
+        if (callout.children[i].id === type || (uptime && callout.children[i].id === "callout-uptime")) {
             callout.children[i].style.display = "block";
         } else {
             callout.children[i].style.display = "none";
@@ -417,6 +671,35 @@ function callout(event, type) {
     }
     document.sb_callout = true;
 }
+function callout_uptime(ele) {
+    let info = document.getElementById("callout-uptime");
+    if (info === null || !ele || !ele.dataset || !ele.dataset.uptime) {
+        return false;
+    }
+    let up = !ele.classList.contains("offline");
+    if (ele.classList.contains("service")) {
+        up = ele.style.backgroundColor === "rgb(40, 111, 36)";
+    }
+    let since = parseInt(ele.dataset.since), mttr = parseInt(ele.dataset.mttr);
+    let text = "Uptime " + ele.dataset.uptime + "%";
+    if (mttr > 0) {
+        text += ", MTTR " + format_duration(mttr);
+    }
+    if (since > 0) {
+        text += ", " + (up ? "up" : "down") + " for " + format_duration(Math.max(0, Math.floor((server_time() - since) / 1000)));
+    }
+    info.innerText = text;
+    return true;
+}
+function format_duration(seconds) {
+    if (seconds < 60) {
+        return seconds + "s";
+    }
+    if (seconds < 3600) {
+        return Math.floor(seconds / 60) + "m";
+    }
+    return Math.floor(seconds / 3600) + "h " + Math.floor((seconds % 3600) / 60) + "m";
+}
 function handle_update(update) {
     debug("Processing '" + JSON.stringify(update) + "'..")
     if (update.event) {
@@ -460,6 +743,14 @@ function handle_update(update) {
         target.innerText = update.value;
         return;
     }
+    if (update.name.startsWith("data-")) {
+        target.setAttribute(update.name, update.value);
+        return;
+    }
+    if (update.name === "order") {
+        order_element(target, parseInt(update.value));
+        return;
+    }
     if (update.name !== "class") {
         target.style[update.name] = update.value;
         return;
@@ -481,6 +772,24 @@ function handle_update(update) {
         }
     }
 }
+function order_element(ele, order) {
+    ele.dataset.order = order;
+    let parent = ele.parentElement;
+    if (parent === null) {
+        return;
+    }
+    let entries = Array.from(parent.children);
+    entries.sort(function(a, b) {
+        let x = a.dataset.order ? parseInt(a.dataset.order) : Infinity;
+        let y = b.dataset.order ? parseInt(b.dataset.order) : Infinity;
+        return x - y;
+    });
+    for (let i = 0; i < entries.length; i++) {
+        if (parent.children[i] !== entries[i]) {
+            parent.insertBefore(entries[i], parent.children[i]);
+        }
+    }
+}
 function scroll_beacon(beacon) {
     let bc = beacon.children[0];
     if (bc === null || bc.length === 0) {
@@ -709,6 +1018,9 @@ function select_div(panel, entries, select) {
             }
         }
     }
+    if (panel === "history") {
+        draw_history();
+    }
     let team = document.getElementById("game-team");
     if (team === null) {
         return;
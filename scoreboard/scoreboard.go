@@ -18,8 +18,11 @@ package scoreboard
 
 import (
 	"context"
+	"crypto/subtle"
 	"crypto/tls"
 	"embed"
+	"encoding/json"
+	"io"
 	"io/fs"
 	"net"
 	"net/http"
@@ -43,6 +46,9 @@ import (
 //go:embed html
 var resources embed.FS
 
+// maxIngest is the largest Game document that can be pushed to the ingest endpoint.
+const maxIngest = 8 << 20
+
 type errval struct {
 	e error
 	s string
@@ -65,8 +71,11 @@ type Scoreboard struct {
 	html   *template.Template
 	key    string
 	cert   string
+	ingest string
+	admin  string
 	filter filter
 	expire time.Duration
+	replay bool
 }
 
 // Run begins the listening process for the Scoreboard and the Game ticking threads. This
@@ -81,8 +90,10 @@ func (s *Scoreboard) Run() error {
 	s.BaseContext = func(_ net.Listener) context.Context { return x }
 	signal.Notify(w, syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT)
 	s.log.Info("Starting Scoreboard service..")
+	if s.feed != nil {
+		go s.twitter(x, s.Twitter(s.expire))
+	}
 	go s.listen(&err, c)
-	go s.twitter(x)
 	go s.Start(x)
 	select {
 	case <-w:
@@ -137,9 +148,53 @@ func (c config) New() (*Scoreboard, error) {
 	if err = getTemplate(s.html, x, "scoreboard.html"); err != nil {
 		return nil, &errval{s: "unable to load scoreboard template", e: err}
 	}
+	if err = getTemplate(s.html, x, "offense.html"); err != nil {
+		return nil, &errval{s: "unable to load offense template", e: err}
+	}
+	if len(c.Scorebot) == 0 && len(c.Replay.File) > 0 {
+		// NOTE(dij): Replays do not need Scorebot, this is only used as the base for
+		//            the asset URLs when one is not set.
+		c.Scorebot = "localhost"
+	}
 	if s.Manager, err = game.New(c.Scorebot, c.Assets, time.Duration(c.Tick)*time.Second, t, s.log); err != nil {
 		return nil, &errval{s: "unable to setup game manager", e: err}
 	}
+	s.SetWorkers(c.Workers)
+	if err = s.SetRanking(c.Ranking.Metric, c.Ranking.Order); err != nil {
+		return nil, &errval{s: "unable to setup Team ranking", e: err}
+	}
+	if err = s.SetActivity(c.Activity.Announce, c.Activity.Games); err != nil {
+		return nil, &errval{s: "unable to setup activity ticker", e: err}
+	}
+	if err = s.SetMilestones(c.Rules); err != nil {
+		return nil, &errval{s: "unable to setup milestone events", e: err}
+	}
+	s.SetUptimeCallouts(c.Callouts)
+	s.SetHistory(time.Duration(c.History.Interval)*time.Second, time.Duration(c.History.Retention)*time.Minute)
+	if err = s.SetAuth(c.Auth); err != nil {
+		return nil, &errval{s: "unable to setup Scorebot authentication", e: err}
+	}
+	if len(c.State) > 0 && len(c.Replay.File) == 0 {
+		if err = s.SetState(c.State); err != nil {
+			return nil, &errval{s: `unable to use state directory "` + c.State + `"`, e: err}
+		}
+	}
+	if len(c.Record.File) > 0 {
+		if err = s.SetRecord(c.Record.File, int64(c.Record.Size)<<20); err != nil {
+			return nil, &errval{s: `unable to open capture file "` + c.Record.File + `"`, e: err}
+		}
+		s.log.Info(`Recording Scorebot data to "%s"..`, c.Record.File)
+	}
+	if len(c.Replay.File) > 0 {
+		if err = s.SetReplay(c.Replay.File, c.Replay.Speed, time.Duration(c.Twitter.Expire)*time.Second); err != nil {
+			return nil, &errval{s: `unable to load replay "` + c.Replay.File + `"`, e: err}
+		}
+		s.replay = true
+		s.log.Info(`Replaying capture "%s" at %sx speed..`, c.Replay.File, strconv.FormatFloat(c.Replay.Speed, 'f', -1, 64))
+	}
+	if c.Auth.Insecure {
+		s.log.Warning("Scorebot TLS verification is disabled, this should only be used for testing!")
+	}
 	s.Server = &http.Server{
 		Addr:              c.Listen,
 		Handler:           new(http.ServeMux),
@@ -176,20 +231,30 @@ func (c config) New() (*Scoreboard, error) {
 		}
 		s.filter, s.expire = c.Twitter.Filter, time.Duration(c.Twitter.Expire)*time.Second
 		s.log.Info("Twitter setup successful!")
-	} else {
+	} else if !s.replay {
 		s.log.Warning("Missing Twitter keys and/or filter parameters, skipping Twitter setup!")
 	}
 	s.key, s.cert = c.Key, c.Cert
 	s.fs, s.dir = http.FileServer(http.FS(&s)), http.Dir(p)
 	s.Server.Handler.(*http.ServeMux).HandleFunc("/", s.http)
 	s.Server.Handler.(*http.ServeMux).HandleFunc("/w", s.httpWebsocket)
+	s.Server.Handler.(*http.ServeMux).HandleFunc("/history/", s.httpHistory)
+	s.Server.Handler.(*http.ServeMux).HandleFunc("/uptime/", s.httpUptime)
+	s.Server.Handler.(*http.ServeMux).HandleFunc("/beacons/", s.httpBeacons)
+	if len(c.Ingest) > 0 {
+		s.ingest = c.Ingest
+		s.Server.Handler.(*http.ServeMux).HandleFunc("/ingest/", s.httpIngest)
+		s.log.Info("Scorebot push ingest endpoint enabled.")
+	}
+	if len(c.Admin) > 0 && s.replay {
+		s.admin = c.Admin
+		s.Server.Handler.(*http.ServeMux).HandleFunc("/admin/replay", s.httpReplay)
+		s.log.Info("Replay control endpoint enabled.")
+	}
 	return &s, nil
 }
-func (s *Scoreboard) twitter(x context.Context) {
-	if s.feed == nil {
-		return
-	}
-	for c := s.Twitter(s.expire); ; {
+func (s *Scoreboard) twitter(x context.Context, c chan<- *twitter.Tweet) {
+	for {
 		select {
 		case <-x.Done():
 			close(c)
@@ -286,7 +351,7 @@ func (s *Scoreboard) http(w http.ResponseWriter, r *http.Request) {
 	}
 	if w.Header().Set("Access-Control-Allow-Origin", `"*"`); len(r.URL.Path) <= 1 || r.URL.Path == "/" {
 		w.Header().Set("Content-Type", "text/html; charset=utf-8")
-		if err := s.html.ExecuteTemplate(w, "home.html", s.Games); err != nil {
+		if err := s.html.ExecuteTemplate(w, "home.html", s.Games()); err != nil {
 			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 			s.log.Error(`Error during request from "%s": %s`, r.RemoteAddr, err.Error())
 		}
@@ -294,6 +359,7 @@ func (s *Scoreboard) http(w http.ResponseWriter, r *http.Request) {
 	}
 	var (
 		v uint64
+		f = "scoreboard.html"
 		n = strings.Trim(r.URL.Path, "/")
 		i = strings.IndexRune(n, '/')
 	)
@@ -305,7 +371,16 @@ func (s *Scoreboard) http(w http.ResponseWriter, r *http.Request) {
 	case i < 0:
 		v = s.Game(n)
 	case strings.ToLower(n[:i]) == "game":
-		if x, err := strconv.Atoi(n[i+1:]); err == nil {
+		k := n[i+1:]
+		if j := strings.IndexRune(k, '/'); j > 0 {
+			// NOTE(dij): Only the offense view is supported as a sub-page, anything
+			//            else falls through to the file server.
+			if !strings.EqualFold(k[j+1:], "offense") {
+				break
+			}
+			k, f = k[:j], "offense.html"
+		}
+		if x, err := strconv.Atoi(k); err == nil {
 			v = uint64(x)
 		}
 	}
@@ -315,7 +390,7 @@ func (s *Scoreboard) http(w http.ResponseWriter, r *http.Request) {
 	}
 	s.log.Debug(`Received scoreboard request from "%s"..`, r.RemoteAddr)
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
-	if err := s.html.ExecuteTemplate(w, "scoreboard.html", &display{Game: v, Twitter: s.feed != nil}); err != nil {
+	if err := s.html.ExecuteTemplate(w, f, &display{Game: v, Twitter: s.feed != nil || s.replay}); err != nil {
 		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 		s.log.Error(`Error during request from "%s": %s!`, r.RemoteAddr, err.Error())
 	}
@@ -328,3 +403,145 @@ func (s *Scoreboard) httpWebsocket(w http.ResponseWriter, r *http.Request) {
 	}
 	s.New(c)
 }
+func (s *Scoreboard) httpUptime(w http.ResponseWriter, r *http.Request) {
+	s.httpGameJSON(w, r, "/uptime", func(i uint64) (interface{}, bool) {
+		v, ok := s.Uptime(i)
+		return v, ok
+	})
+}
+func (s *Scoreboard) httpBeacons(w http.ResponseWriter, r *http.Request) {
+	s.httpGameJSON(w, r, "/beacons", func(i uint64) (interface{}, bool) {
+		v, ok := s.Beacons(i)
+		return v, ok
+	})
+}
+func (s *Scoreboard) httpHistory(w http.ResponseWriter, r *http.Request) {
+	s.httpGameJSON(w, r, "/history", func(i uint64) (interface{}, bool) {
+		v, ok := s.History(i)
+		return v, ok
+	})
+}
+
+// httpGameJSON handles a GET request for a Game stats endpoint. The Game ID is read from
+// the path after the prefix and the value returned by the function is sent as JSON.
+func (s *Scoreboard) httpGameJSON(w http.ResponseWriter, r *http.Request, p string, f func(uint64) (interface{}, bool)) {
+	if r.Method != http.MethodGet {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	i, err := strconv.ParseUint(strings.Trim(strings.TrimPrefix(r.URL.Path, p), "/"), 10, 64)
+	if err != nil || i == 0 {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	v, ok := f(i)
+	if !ok {
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if err = json.NewEncoder(w).Encode(v); err != nil {
+		s.log.Error(`Error during request from "%s": %s!`, r.RemoteAddr, err.Error())
+	}
+}
+func (s *Scoreboard) httpIngest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	a := r.Header.Get("Authorization")
+	if len(a) < 7 || !strings.EqualFold(a[:7], "bearer ") || subtle.ConstantTimeCompare([]byte(a[7:]), []byte(s.ingest)) != 1 {
+		s.log.Warning(`Rejected unauthorized ingest request from "%s"!`, r.RemoteAddr)
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+	v, err := strconv.ParseUint(strings.Trim(strings.TrimPrefix(r.URL.Path, "/ingest"), "/"), 10, 64)
+	if err != nil || v == 0 {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	b, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxIngest))
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusRequestEntityTooLarge), http.StatusRequestEntityTooLarge)
+		return
+	}
+	s.log.Debug(`Received ingest request for Game %d from "%s"..`, v, r.RemoteAddr)
+	if err = s.Ingest(r.Context(), v, b); err != nil {
+		s.log.Error(`Error during ingest request from "%s": %s!`, r.RemoteAddr, err.Error())
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// httpReplay handles the replay control endpoint. A GET request returns the state of the
+// replay clock, while a POST request changes it using the "action" form value:
+//
+//	pause:   Stop the replay clock.
+//	resume:  Start the replay clock.
+//	seek:    Move the replay clock by the "offset" duration (ex: "-5m" or "30s").
+//	jump:    Move the replay clock to the "time" value (RFC3339 or Unix milliseconds).
+//	speed:   Set the replay speed multiplier to the "speed" value.
+//
+// Both requests return the (updated) replay state as JSON.
+func (s *Scoreboard) httpReplay(w http.ResponseWriter, r *http.Request) {
+	a := r.Header.Get("Authorization")
+	if len(a) < 7 || !strings.EqualFold(a[:7], "bearer ") || subtle.ConstantTimeCompare([]byte(a[7:]), []byte(s.admin)) != 1 {
+		s.log.Warning(`Rejected unauthorized replay request from "%s"!`, r.RemoteAddr)
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		if err := s.replayAction(r); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	default:
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	v, _ := s.ReplayState()
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		s.log.Error(`Error during replay request from "%s": %s!`, r.RemoteAddr, err.Error())
+	}
+}
+func (s *Scoreboard) replayAction(r *http.Request) error {
+	switch a := r.FormValue("action"); a {
+	case "pause":
+		s.ReplayPause(true)
+	case "resume":
+		s.ReplayPause(false)
+	case "seek":
+		d, err := time.ParseDuration(r.FormValue("offset"))
+		if err != nil {
+			return &errval{s: `invalid offset "` + r.FormValue("offset") + `"`}
+		}
+		v, _ := s.ReplayState()
+		s.ReplaySeek(v.Time.Add(d))
+	case "jump":
+		v := r.FormValue("time")
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+			s.ReplaySeek(time.UnixMilli(n))
+			break
+		}
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return &errval{s: `invalid time "` + v + `"`}
+		}
+		s.ReplaySeek(t)
+	case "speed":
+		v, err := strconv.ParseFloat(r.FormValue("speed"), 64)
+		if err != nil || v <= 0 {
+			return &errval{s: `invalid speed "` + r.FormValue("speed") + `"`}
+		}
+		s.ReplaySpeed(v)
+	default:
+		return &errval{s: `unknown action "` + a + `"`}
+	}
+	s.log.Info(`Replay action "%s" from "%s".`, r.FormValue("action"), r.RemoteAddr)
+	return nil
+}